@@ -0,0 +1,81 @@
+package main
+
+import (
+	"advent-2019/intcode"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// record runs the tape interactively, and saves a transcript of the session to path
+func record(tape *intcode.Tape, path string) {
+	transcript, runErr := intcode.NewASCII(tape).Record(os.Stdin, os.Stdout)
+
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	if err := transcript.Save(file); err != nil {
+		log.Fatal(err)
+	}
+	if runErr != nil {
+		log.Fatal(runErr)
+	}
+}
+
+// replay runs the tape with the input from the transcript at path, and checks it gives the same output
+func replay(tape *intcode.Tape, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	transcript, err := intcode.LoadTranscript(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := transcript.Replay(tape); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Replayed %d lines of input, output matches\n", len(transcript.Inputs()))
+}
+
+func main() {
+	recordPath := flag.String("record", "", "save a transcript of the session to this file")
+	replayPath := flag.String("replay", "", "replay the session in this transcript file, and check the output is the same")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ascii [-record file | -replay file] <tape file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || (*recordPath != "" && *replayPath != "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	data, err := intcode.ReadTapeData(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tape := intcode.CreateTapeCopy(data)
+	switch {
+	case *recordPath != "":
+		record(&tape, *recordPath)
+	case *replayPath != "":
+		replay(&tape, *replayPath)
+	default:
+		if err := intcode.NewASCII(&tape).Interact(os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"advent-2019/intcode"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	outputPath := flag.String("o", "", "file to write the tape to (defaults to stdout)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: asm [-o tape file] <source file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	source, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer source.Close()
+
+	data, err := intcode.Assemble(source)
+	if err != nil {
+		log.Fatalf("%s: %v", flag.Arg(0), err)
+	}
+
+	output := os.Stdout
+	if *outputPath != "" {
+		if output, err = os.Create(*outputPath); err != nil {
+			log.Fatal(err)
+		}
+		defer output.Close()
+	}
+
+	if _, err := fmt.Fprintln(output, intcode.FormatTapeData(data)); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"advent-2019/intcode"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	entries := flag.String("entry", "", "comma-separated extra addresses to start from")
+	summary := flag.Bool("summary", false, "list blocks, functions and unreached ranges instead of writing DOT")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cfg [flags] <tape file>")
+		fmt.Fprintln(os.Stderr, "Writes the control-flow graph of a tape in Graphviz DOT format, e.g. cfg day9.txt | dot -Tsvg > day9.svg")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	data, err := intcode.ReadTapeData(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	entryPoints, err := intcode.ParseValues(*entries)
+	if err != nil {
+		log.Fatal("Invalid entry point: ", err)
+	}
+	graph := intcode.BuildCFG(data, entryPoints...)
+	if !*summary {
+		if err := graph.WriteDot(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	fmt.Printf("%d blocks, %d functions\n", len(graph.Blocks), len(graph.Functions))
+	for _, b := range graph.Blocks {
+		var successors []string
+		for _, edge := range b.Successors {
+			successors = append(successors, fmt.Sprintf("%s %04d", edge.Kind, edge.To))
+		}
+		note := ""
+		if b.Return {
+			note = " (returns)"
+		} else if b.ComputedJump {
+			note = " (computed jump)"
+		}
+		fmt.Printf("%04d-%04d  function %04d%s  -> %s\n", b.Start, b.End-1, b.Function, note, strings.Join(successors, ", "))
+	}
+	for _, span := range graph.Unreached() {
+		fmt.Printf("unreached %04d-%04d\n", span.Start, span.End-1)
+	}
+}
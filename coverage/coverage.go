@@ -0,0 +1,128 @@
+package main
+
+import (
+	"advent-2019/intcode"
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// readRuns reads one set of comma-separated inputs per line
+func readRuns(path string) ([][]int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var runs [][]int
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		inputs, err := intcode.ParseValues(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		runs = append(runs, inputs)
+	}
+	return runs, scanner.Err()
+}
+
+// run runs a fresh copy of the tape with the given inputs, recording coverage, until it stops
+// for anything other than output
+func run(data []int, inputs []int, steps int, coverage *intcode.Coverage) (intcode.Status, error) {
+	tape := intcode.CreateTapeCopy(data)
+	for _, x := range inputs {
+		tape.Input(x)
+	}
+	tape.Attach(coverage)
+
+	status, _, err := tape.Run(steps)
+	for status == intcode.ProducedOutput {
+		status, _, err = tape.Run(steps)
+	}
+	return status, err
+}
+
+func main() {
+	input := flag.String("input", "", "comma-separated values to queue as input")
+	inputsPath := flag.String("inputs", "", "file with one comma-separated set of inputs per line, each run separately")
+	steps := flag.Int("steps", 10000000, "stop each run after this many instructions between outputs (0 for no limit)")
+	dataPath := flag.String("data", "", "coverage file to merge with, and to save the merged coverage to")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: coverage [flags] <tape file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := intcode.ReadTapeData(file)
+	file.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var runs [][]int
+	if *inputsPath != "" {
+		if runs, err = readRuns(*inputsPath); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		inputs, err := intcode.ParseValues(*input)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runs = [][]int{inputs}
+	}
+
+	coverage := intcode.NewCoverage()
+	if *dataPath != "" {
+		saved, err := os.Open(*dataPath)
+		if err == nil {
+			coverage, err = intcode.LoadCoverage(saved)
+			saved.Close()
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Fatal(err)
+		}
+	}
+
+	for i, inputs := range runs {
+		status, err := run(data, inputs, *steps, coverage)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "run %d: %v\n", i+1, err)
+		} else if status != intcode.Halted {
+			fmt.Fprintf(os.Stderr, "run %d: %s\n", i+1, status)
+		}
+	}
+
+	if err := coverage.WriteAnnotatedListing(os.Stdout, data); err != nil {
+		log.Fatal(err)
+	}
+
+	if *dataPath != "" {
+		out, err := os.Create(*dataPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := coverage.Save(out); err != nil {
+			log.Fatal(err)
+		}
+		if err := out.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
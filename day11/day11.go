@@ -5,6 +5,7 @@ import (
 	"advent-2019/intcode"
 	"advent-2019/point"
 	"advent-2019/turtle"
+	"log"
 )
 
 const (
@@ -17,17 +18,38 @@ const (
 	paintWhite = 1
 )
 
+// nextOutput runs the tape until it outputs a value, and returns false if it halts first
+func nextOutput(tape *intcode.Tape) (int, bool) {
+	status, value, err := tape.Run(0)
+	switch status {
+	case intcode.ProducedOutput:
+		return value, true
+	case intcode.Halted:
+		return 0, false
+	case intcode.Faulted:
+		log.Fatal(err)
+	default:
+		log.Fatal("Unexpected tape status: ", status)
+	}
+	return 0, false
+}
+
 func solve() {
 	grid := colorgrid.Grid{}
 	grid[point.Point{}] = colorgrid.White
 	robot := turtle.Turtle{}
-	tape := intcode.CreateBlankTape("advent-2019/day11.txt")
-	for !tape.IsHalted() {
+	tape, err := intcode.CreateBlankTape("advent-2019/day11.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for {
 		tape.Input(int(grid[robot.Pos()]))
-		color := tape.RunUntilNextOutput()
-		turnDir := tape.RunUntilNextOutput()
-
-		if tape.IsHalted() {
+		color, ok := nextOutput(&tape)
+		if !ok {
+			break
+		}
+		turnDir, ok := nextOutput(&tape)
+		if !ok {
 			break
 		}
 
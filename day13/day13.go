@@ -6,6 +6,7 @@ import (
 	"advent-2019/smath"
 	"fmt"
 	"github.com/logrusorgru/aurora"
+	"log"
 )
 
 const (
@@ -45,16 +46,38 @@ func (g *Game) setTile(pos point.Point, tile int) {
 	g.grid[pos] = tile
 }
 
-func (g *Game) Play() {
+// readOutputs runs the tape until it has produced three more outputs, and returns false if it halts first
+func readOutputs(tape *intcode.Tape) (int, int, int, bool, error) {
+	var outputs [3]int
+	for i := range outputs {
+		status, output, err := tape.Run(0)
+		switch status {
+		case intcode.ProducedOutput:
+			outputs[i] = output
+		case intcode.Halted:
+			return 0, 0, 0, false, nil
+		case intcode.Faulted:
+			return 0, 0, 0, false, err
+		default:
+			return 0, 0, 0, false, fmt.Errorf("unexpected tape status: %v", status)
+		}
+	}
+	return outputs[0], outputs[1], outputs[2], true, nil
+}
+
+func (g *Game) Play() error {
 	tape := &g.tape
-	tape.Set(0, 2)
+	if err := tape.Set(0, 2); err != nil {
+		return err
+	}
 
-	for !tape.IsHalted() {
+	for {
 		tape.Input(0)
 
-		x := tape.RunUntilNextOutput()
-		y := tape.RunUntilNextOutput()
-		tileOrScore := tape.RunUntilNextOutput()
+		x, y, tileOrScore, ok, err := readOutputs(tape)
+		if err != nil || !ok {
+			return err
+		}
 
 		if x == -1 && y == 0 {
 			g.score = tileOrScore
@@ -67,12 +90,19 @@ func (g *Game) Play() {
 }
 
 func part1() {
-	tape := intcode.CreateBlankTape("advent-2019/day13.txt")
+	tape, err := intcode.CreateBlankTape("advent-2019/day13.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
 	grid := map[point.Point]int{}
-	for !tape.IsHalted() {
-		x := tape.RunUntilNextOutput()
-		y := tape.RunUntilNextOutput()
-		tile := tape.RunUntilNextOutput()
+	for {
+		x, y, tile, ok, err := readOutputs(&tape)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			break
+		}
 		grid[point.Point{x, y}] = tile
 	}
 	blockCount := 0
@@ -116,7 +146,10 @@ func print(grid map[point.Point]int) {
 }
 
 func part2() {
-	tape := intcode.CreateBlankTape("advent-2019/day13.txt")
+	tape, err := intcode.CreateBlankTape("advent-2019/day13.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
 	game := Game{
 		tape:      tape,
 		grid:      map[point.Point]int{},
@@ -125,7 +158,9 @@ func part2() {
 		paddlePos: point.Point{-1, -1},
 		score:     0,
 	}
-	game.Play()
+	if err := game.Play(); err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println("Score:", game.score)
 }
 
@@ -6,6 +6,7 @@ import (
 	"advent-2019/point"
 	"advent-2019/turtle"
 	"fmt"
+	"log"
 )
 
 const (
@@ -60,13 +61,16 @@ func (system System) AdjacentPositions(pos point.Point) []point.Point {
 	return positions
 }
 
-func (system *System) MoveRobot(direction turtle.Direction) bool {
+func (system *System) MoveRobot(direction turtle.Direction) (bool, error) {
 	system.tape.ClearInput()
 	system.tape.Input(directionToMoveCommand[direction])
 
 	targetPos := turtle.NextPosInDir(system.turtle.Pos(), direction)
 
-	result := system.tape.RunUntilNextOutput()
+	result, err := system.tape.RunUntilNextOutput()
+	if err != nil {
+		return false, err
+	}
 
 	system.grid[targetPos] = resultCodeToTileId[result]
 
@@ -74,20 +78,23 @@ func (system *System) MoveRobot(direction turtle.Direction) bool {
 	if moveSuccess {
 		system.turtle.MoveInDir(direction)
 	}
-	return moveSuccess
+	return moveSuccess, nil
 }
 
 func (system System) CurrentTile() int {
 	return system.grid[system.turtle.Pos()]
 }
 
-func (system *System) Explore(dir turtle.Direction, visited map[point.Point]bool) {
-	result := system.MoveRobot(dir)
-	if !result {
-		return
-	}
+// Explore moves the robot in dir and explores everything reachable from there, then puts the robot
+// back where it started by restoring the tape rather than walking back
+func (system *System) Explore(dir turtle.Direction, visited map[point.Point]bool) error {
+	snapshot := system.tape.Snapshot()
+	robot := system.turtle
 
-	defer system.MoveRobot(dir.Opposite())
+	result, err := system.MoveRobot(dir)
+	if err != nil || !result {
+		return err
+	}
 
 	for _, nextDir := range turtle.Directions {
 		posInDir := turtle.NextPosInDir(system.turtle.Pos(), nextDir)
@@ -95,7 +102,23 @@ func (system *System) Explore(dir turtle.Direction, visited map[point.Point]bool
 			continue
 		}
 
-		system.Explore(nextDir, visited)
+		if err := system.Explore(nextDir, visited); err != nil {
+			return err
+		}
+	}
+
+	system.tape.Restore(snapshot)
+	system.turtle = robot
+	return nil
+}
+
+// ExploreAll explores the area around the robot in every direction
+func (system *System) ExploreAll() {
+	visited := map[point.Point]bool{}
+	for _, dir := range turtle.Directions {
+		if err := system.Explore(dir, visited); err != nil {
+			log.Fatal(err)
+		}
 	}
 }
 
@@ -190,21 +213,23 @@ func (system System) Print() {
 }
 
 func part1() {
-	system := System{tape: intcode.CreateBlankTape("advent-2019/day15.txt"), grid: map[point.Point]int{}, turtle: turtle.Turtle{}}
-	visited := map[point.Point]bool{}
-	for _, dir := range turtle.Directions {
-		system.Explore(dir, visited)
+	tape, err := intcode.CreateBlankTape("advent-2019/day15.txt")
+	if err != nil {
+		log.Fatal(err)
 	}
+	system := System{tape: tape, grid: map[point.Point]int{}, turtle: turtle.Turtle{}}
+	system.ExploreAll()
 	oxygenLocation := system.GetOxygenLocation()
 	fmt.Println(system.DistanceBetween(point.Point{0, 0}, oxygenLocation))
 }
 
 func part2() {
-	system := System{tape: intcode.CreateBlankTape("advent-2019/day15.txt"), grid: map[point.Point]int{}, turtle: turtle.Turtle{}}
-	visited := map[point.Point]bool{}
-	for _, dir := range turtle.Directions {
-		system.Explore(dir, visited)
+	tape, err := intcode.CreateBlankTape("advent-2019/day15.txt")
+	if err != nil {
+		log.Fatal(err)
 	}
+	system := System{tape: tape, grid: map[point.Point]int{}, turtle: turtle.Turtle{}}
+	system.ExploreAll()
 	oxygenLocation := system.GetOxygenLocation()
 	queue := point.Queue{}
 	queue.Push(oxygenLocation)
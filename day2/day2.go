@@ -1,118 +1,66 @@
 package main
 
 import (
-	"bufio"
+	"advent-2019/intcode"
+	"errors"
 	"fmt"
 	"log"
-	"strconv"
-	"strings"
-	"util/datafile"
 )
 
-const (
-	add      int = 1
-	multiply int = 2
-	halt     int = 99
-)
-
-type tape struct {
-	data   []int
-	cursor int
-}
-
-// GetParams extracts [paramCount] params, and advances the cursor to the instruction that will happen next
-func (t *tape) GetParams(paramCount int) []int {
-	params := make([]int, paramCount)
-
-	cursor := (*t).cursor + 1
-	for i := 0; i < paramCount; i++ {
-		params[i] = (*t).data[cursor+i]
+func getTapeData() []int {
+	data, err := intcode.GetTapeData("advent-2019/day2.txt")
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	(*t).cursor += (paramCount + 1)
-
-	return params
+	return data
 }
 
-// Value returns the value/opcode at the cursor
-func (t tape) Value() int {
-	return t.data[t.cursor]
-}
+// maxInstructions is how long a run can take before it is abandoned, as some nouns and verbs
+// turn the program into one which never halts
+const maxInstructions = 1000000
 
-// Output returns the value at the first index, aka the output.
-// This return value is invalid if the tape has not been run
-func (t tape) Output() int {
-	return t.data[0]
-}
+// runWithInputs runs a copy of the program with the given noun and verb, and returns the tape
+// once it has halted
+func runWithInputs(data []int, noun int, verb int) (intcode.Tape, error) {
+	t := intcode.CreateTapeCopy(data)
+	t.SetInstructionLimit(maxInstructions)
 
-// Run will run the tape from the current data/cursor until it halts or hits an unknown opcode
-func (t tape) Run() {
-	for t.Value() != halt {
-		opcode := t.Value()
-
-		switch opcode {
-		case add:
-			{
-				params := t.GetParams(3)
-				inputIndexA := params[0]
-				inputIndexB := params[1]
-				outputIndex := params[2]
-				t.data[outputIndex] = t.data[inputIndexA] + t.data[inputIndexB]
-			}
-		case multiply:
-			{
-				params := t.GetParams(3)
-				inputIndexA := params[0]
-				inputIndexB := params[1]
-				outputIndex := params[2]
-				t.data[outputIndex] = t.data[inputIndexA] * t.data[inputIndexB]
-			}
-		default:
-			log.Fatal("Invalid opcode: ", opcode)
-		}
+	if err := t.Set(1, noun); err != nil {
+		return t, err
 	}
-}
-
-func getTapeData() []int {
-	file := datafile.Open("advent-2019/day2.txt")
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	scanner.Scan()
-	line := scanner.Text()
-
-	numberStrings := strings.Split(line, ",")
-	data := make([]int, len(numberStrings))
-	for i, numberString := range numberStrings {
-		number, err := strconv.Atoi(numberString)
-		if err != nil {
-			log.Fatal(err)
-		}
-		data[i] = int(number)
+	if err := t.Set(2, verb); err != nil {
+		return t, err
 	}
 
-	return data
+	return t, t.RunUntilHalt()
 }
 
-func createBlankTape() tape {
-	data := getTapeData()
-	return tape{data, 0}
+// invalidProgram returns whether a run failed because its noun and verb turned the program into
+// one which can't run, rather than for any other reason
+func invalidProgram(err error) bool {
+	return errors.Is(err, intcode.ErrInvalidOpcode) || errors.Is(err, intcode.ErrInvalidMode) ||
+		errors.Is(err, intcode.ErrOutOfBounds) || errors.Is(err, intcode.ErrStepLimit)
 }
 
 func part1() {
 	fmt.Println("Creating tape")
 
-	t := createBlankTape()
-
-	t.data[1] = 12
-	t.data[2] = 2
+	data := getTapeData()
 
 	fmt.Println("Running tape")
 
-	t.Run()
+	t, err := runWithInputs(data, 12, 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	memory := make([]int, len(data))
+	for i := range memory {
+		memory[i], _ = t.Peek(i)
+	}
 
 	fmt.Println("Halt")
-	fmt.Println(t.data)
+	fmt.Println(memory)
 }
 
 func findNounAndVerb() (int, int) {
@@ -122,16 +70,15 @@ func findNounAndVerb() (int, int) {
 
 	for noun := 0; noun < 100; noun++ {
 		for verb := 0; verb < 100; verb++ {
-			data := make([]int, len(baseData))
-			copy(data, baseData)
-
-			data[1] = noun
-			data[2] = verb
-
-			t := tape{data, 0}
-			t.Run()
+			t, err := runWithInputs(baseData, noun, verb)
+			if invalidProgram(err) {
+				continue
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
 
-			if t.Output() == desiredOutput {
+			if t.First() == desiredOutput {
 				return noun, verb
 			}
 		}
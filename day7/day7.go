@@ -1,266 +1,19 @@
 package main
 
 import (
-	"bufio"
+	"advent-2019/intcode"
 	"fmt"
 	"log"
-	"os"
-	"strconv"
-	"strings"
-	"sync"
-	"util/datafile"
 )
 
-const (
-	addOpcode         int = 1
-	multiplyOpcode    int = 2
-	inputOpcode       int = 3
-	outputOpcode      int = 4
-	jumpIfTrueOpcode  int = 5
-	jumpIfFalseOpcode int = 6
-	lessThanOpcode    int = 7
-	equalsOpcode      int = 8
-	haltOpcode        int = 99
-)
-
-const (
-	positionMode  = 0
-	immediateMode = 1
-)
-
-type param struct {
-	value int
-	mode  int
-}
-
-type tape struct {
-	data   []int
-	cursor int
-	input  chan int
-	output *chan int
-}
-
-func getChar(s string, pos int) byte {
-	if pos >= len(s) || pos < 0 {
-		return '0'
-	}
-
-	return s[len(s)-pos-1]
-}
-
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-func decodeOpcode(value int) int {
-	valueString := strconv.Itoa(value)
-
-	opcodeString := string(getChar(valueString, 1)) + string(getChar(valueString, 0))
-	opcode, err := strconv.Atoi(opcodeString)
+func getTapeData() []int {
+	data, err := intcode.GetTapeData("advent-2019/day7.txt")
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	return opcode
-}
-
-func prompt() string {
-	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Scan()
-	return scanner.Text()
-}
-
-func (t tape) IsHalted() bool {
-	return t.Value() == haltOpcode
-}
-
-// GetParams extracts [paramCount] params, and advances the cursor to the instruction that will happen next
-func (t *tape) GetParams(instructionValue int, paramCount int) []param {
-	instructionString := strconv.Itoa(instructionValue)
-	params := make([]param, paramCount)
-
-	cursor := t.cursor + 1
-	for i := 0; i < paramCount; i++ {
-		paramValue := t.data[cursor+i]
-		modeString := getChar(instructionString, i+2)
-		mode, err := strconv.Atoi(string(modeString))
-		if err != nil {
-			log.Fatal(err)
-		}
-		params[i] = param{paramValue, mode}
-	}
-
-	t.cursor += paramCount + 1
-
-	return params
-}
-
-// Value returns the value/opcode at the cursor
-func (t tape) Value() int {
-	return t.data[t.cursor]
-}
-
-// Resolve returns the value of a parameter, based on its mode
-func (t tape) Resolve(p param) int {
-	switch p.mode {
-	case positionMode:
-		return t.data[p.value]
-	case immediateMode:
-		return p.value
-	default:
-		log.Fatal("Invalid mode:", p.mode)
-		return 0 // this will never be hit
-	}
-}
-
-// Output returns the value at the first index, aka the output.
-// This return value is invalid if the tape has not been run
-func (t tape) Output() int {
-	return t.data[0]
-}
-
-// Run will run the tape from the current data/cursor until it halts or hits an unknown opcode
-func (t *tape) Run(wg *sync.WaitGroup, i int) {
-	if wg != nil {
-		wg.Add(1)
-		defer wg.Done()
-	}
-
-	for !t.IsHalted() {
-		value := t.Value()
-		opcode := decodeOpcode(value)
-
-		// fmt.Println("Processing value:", value)
-		// fmt.Println("Current data:", t.data)
-		// fmt.Println("Opcode:", opcode)
-
-		switch opcode {
-		case addOpcode:
-			{
-				params := t.GetParams(value, 3)
-				a := t.Resolve(params[0])
-				b := t.Resolve(params[1])
-				outputIndex := params[2].value
-				t.data[outputIndex] = a + b
-			}
-		case multiplyOpcode:
-			{
-				params := t.GetParams(value, 3)
-				a := t.Resolve(params[0])
-				b := t.Resolve(params[1])
-				outputIndex := params[2].value
-				t.data[outputIndex] = a * b
-			}
-		case inputOpcode:
-			{
-				params := t.GetParams(value, 1)
-				destination := params[0].value
-				//fmt.Println(i, "Waiting for input")
-				t.data[destination] = <-t.input
-				//fmt.Println(i, "Got input:", t.data[destination])
-			}
-		case outputOpcode:
-			{
-				params := t.GetParams(value, 1)
-				value := t.Resolve(params[0])
-				//fmt.Println(i, "Sending output", value)
-				*t.output <- value
-				//fmt.Println(i, "Sent output")
-			}
-		case jumpIfTrueOpcode:
-			{
-				params := t.GetParams(value, 2)
-				testValue := t.Resolve(params[0])
-				jumpIndex := t.Resolve(params[1])
-				if testValue != 0 {
-					t.cursor = jumpIndex
-				}
-			}
-		case jumpIfFalseOpcode:
-			{
-				params := t.GetParams(value, 2)
-				testValue := t.Resolve(params[0])
-				jumpIndex := t.Resolve(params[1])
-				if testValue == 0 {
-					t.cursor = jumpIndex
-				}
-			}
-		case lessThanOpcode:
-			{
-				params := t.GetParams(value, 3)
-				a := t.Resolve(params[0])
-				b := t.Resolve(params[1])
-				destination := params[2].value
-
-				writeValue := 0
-				if a < b {
-					writeValue = 1
-				}
-
-				t.data[destination] = writeValue
-			}
-		case equalsOpcode:
-			{
-				params := t.GetParams(value, 3)
-				a := t.Resolve(params[0])
-				b := t.Resolve(params[1])
-				destination := params[2].value
-
-				writeValue := 0
-				if a == b {
-					writeValue = 1
-				}
-				t.data[destination] = writeValue
-			}
-		default:
-			log.Fatal("Invalid opcode: ", opcode)
-		}
-	}
-	//fmt.Println(i, "Done")
-}
-
-func getTapeData() []int {
-	file := datafile.Open("advent-2019/day7.txt")
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	scanner.Scan()
-	line := scanner.Text()
-
-	numberStrings := strings.Split(line, ",")
-	data := make([]int, len(numberStrings))
-	for i, numberString := range numberStrings {
-		number, err := strconv.Atoi(numberString)
-		if err != nil {
-			log.Fatal(err)
-		}
-		data[i] = int(number)
-	}
-
 	return data
 }
 
-func createBlankTape() tape {
-	data := getTapeData()
-	return tape{data, 0, make(chan int), nil}
-}
-
-func createTapeCopy(data []int) tape {
-	dataCopy := make([]int, len(data))
-	copy(dataCopy, data)
-	return tape{dataCopy, 0, make(chan int), nil}
-}
-
 func permutations(items []int, callback func([]int), i int) {
 	if i > len(items) {
 		callback(items)
@@ -276,34 +29,31 @@ func permutations(items []int, callback func([]int), i int) {
 	}
 }
 
-func wrap(v, l int) int {
-	for v < 0 {
-		v += l
+// runAmplifiers runs a copy of the program for each phase, each amplifier sending its output to
+// the next, and returns the last signal sent by the last amplifier. With feedback, the last
+// amplifier also sends its output back to the first.
+func runAmplifiers(data []int, phases []int, feedback bool) (int, error) {
+	network := intcode.NewNetwork()
+	for _, phase := range phases {
+		tape := intcode.CreateTapeCopy(data)
+		network.Send(network.Add(&tape), phase)
 	}
-
-	return v % l
-}
-
-func linkAmps(amplifiers []tape) {
-	for i := 0; i < len(amplifiers); i++ {
-		amp := &amplifiers[i]
-		nextAmp := &amplifiers[wrap(i+1, len(amplifiers))]
-		amp.output = &nextAmp.input
+	for i := 0; i < len(phases)-1; i++ {
+		network.Link(i, i+1)
 	}
-}
-
-func startAmps(amplifiers []tape, wg *sync.WaitGroup) {
-	lastIndex := len(amplifiers)-1
-
-	for i := 0; i < len(amplifiers); i++ {
-		amp := &amplifiers[i]
-		if i < lastIndex {
-			go amp.Run(wg, i)
-		} else {
-			go amp.Run(nil, i)
-		}
+	if feedback {
+		network.Link(len(phases)-1, 0)
 	}
+	network.Send(0, 0)
 
+	if err := network.Run(intcode.Concurrent); err != nil {
+		return 0, err
+	}
+	signals := network.Outputs(len(phases) - 1)
+	if len(signals) == 0 {
+		return 0, fmt.Errorf("no signal from the last amplifier")
+	}
+	return signals[len(signals)-1], nil
 }
 
 func part1() {
@@ -311,27 +61,11 @@ func part1() {
 	highestOutput := -1
 	var highestOutputPhases []int
 	permutations([]int{0, 1, 2, 3, 4}, func(phases []int) {
-		amplifiers := []tape{
-			createTapeCopy(data),
-			createTapeCopy(data),
-			createTapeCopy(data),
-			createTapeCopy(data),
-			createTapeCopy(data),
-		}
-		linkAmps(amplifiers)
-
-		var wg sync.WaitGroup
-		startAmps(amplifiers, &wg)
-
-		for i, phase := range phases {
-			amplifiers[i].input <- phase
+		output, err := runAmplifiers(data, phases, false)
+		if err != nil {
+			log.Fatal(err)
 		}
 
-		amplifiers[0].input <- 0
-
-		wg.Wait()
-		output := <-amplifiers[0].input
-
 		if highestOutput == -1 || output > highestOutput {
 			highestOutput = output
 			highestOutputPhases = phases
@@ -345,27 +79,11 @@ func part2() {
 	data := getTapeData()
 	highestOutput := -1
 	permutations([]int{5, 6, 7, 8, 9}, func(phases []int) {
-		amplifiers := []tape{
-			createTapeCopy(data),
-			createTapeCopy(data),
-			createTapeCopy(data),
-			createTapeCopy(data),
-			createTapeCopy(data),
-		}
-		linkAmps(amplifiers)
-
-		var wg sync.WaitGroup
-		startAmps(amplifiers, &wg)
-
-		for i, phase := range phases {
-			amplifiers[i].input <- phase
+		output, err := runAmplifiers(data, phases, true)
+		if err != nil {
+			log.Fatal(err)
 		}
 
-		amplifiers[0].input <- 0
-
-		wg.Wait()
-		output := <-amplifiers[0].input
-
 		if highestOutput == -1 || output > highestOutput {
 			highestOutput = output
 		}
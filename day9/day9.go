@@ -3,12 +3,18 @@ package main
 import (
 	"advent-2019/intcode"
 	"fmt"
+	"log"
 )
 
 func part1() {
-	tape := intcode.CreateBlankTape("advent-2019/day9.txt")
+	tape, err := intcode.CreateBlankTape("advent-2019/day9.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
 	tape.Input(2)
-	tape.RunUntilHalt()
+	if err := tape.RunUntilHalt(); err != nil {
+		log.Fatal(err)
+	}
 	output := tape.Output()
 	for !output.Empty() {
 		fmt.Println(output.Pop())
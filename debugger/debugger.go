@@ -0,0 +1,332 @@
+package main
+
+import (
+	"advent-2019/intcode"
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const help = `Commands:
+  s, step [n]            run n instructions (default 1)
+  n, next                step over the current instruction
+  c, continue            run until a breakpoint, watchpoint, halt or error
+  sb, step-back [n]      undo n instructions (default 1)
+  rc, reverse-continue   undo instructions until a breakpoint or watchpoint, or the start of the history
+  history [n]            show how many instructions can be undone, or keep up to n of them
+  b, break <addr>        break before the instruction at addr
+  b, break op <op>       break before any instruction with mnemonic or opcode op
+  w, watch <addr> [rw]   stop after addr is read (r), written (w) or both (rw, default)
+  d, delete <id>         remove a breakpoint or watchpoint
+  i, info                show the cursor, relative base, queues, breakpoints and watchpoints
+  x <addr> [n]           show n memory cells starting at addr (default 1)
+  poke <addr> <value>    write value to addr
+  in <value>...          queue input values
+  out                    take and print all pending output
+  l, list [addr] [n]     disassemble n instructions from addr (default cursor, 10)
+  save <file>            save the state of the tape, to resume later
+  load <file>            replace the state of the tape with a saved one
+  h, help                show this help
+  q, quit                exit`
+
+type session struct {
+	debugger *intcode.Debugger
+	tape     *intcode.Tape
+}
+
+func (s session) printCurrent() {
+	if s.tape.IsHalted() {
+		return
+	}
+	fmt.Println(intcode.FormatLine(s.debugger.List(s.tape.Cursor(), 1)[0]))
+}
+
+func (s session) printStop(stop intcode.Stop) {
+	if stop.Reason == intcode.StoppedFault && errors.Is(stop.Err, intcode.ErrInputExhausted) {
+		fmt.Println("waiting for input")
+	} else if stop.Reason != intcode.StoppedStep {
+		fmt.Println(stop)
+	}
+	s.printCurrent()
+}
+
+func (s session) info() {
+	fmt.Println("cursor:", s.tape.Cursor())
+	fmt.Println("relative base:", s.tape.RelativeBase())
+	fmt.Println("pending input:", s.tape.PendingInput())
+	fmt.Println("pending output:", s.tape.PendingOutput())
+	fmt.Println("history:", s.tape.HistoryLength())
+	for _, b := range s.debugger.Breakpoints() {
+		fmt.Println(b)
+	}
+	for _, w := range s.debugger.Watchpoints() {
+		fmt.Println(w)
+	}
+}
+
+// execute runs a single REPL command, and returns false when the session should end
+func (s session) execute(command string, args []string) (bool, error) {
+	// numbers parses the arguments, which may also be separated by commas, and checks there are
+	// between min and max values
+	numbers := func(min, max int) ([]int, error) {
+		values, err := intcode.ParseValues(strings.Join(args, ","))
+		if err != nil {
+			return nil, err
+		}
+		if len(values) < min || len(values) > max {
+			return nil, fmt.Errorf("%s: wrong number of arguments", command)
+		}
+		return values, nil
+	}
+
+	switch command {
+	case "s", "step":
+		n, err := numbers(0, 1)
+		if err != nil {
+			return true, err
+		}
+		count := 1
+		if len(n) == 1 {
+			count = n[0]
+		}
+		stop := intcode.Stop{}
+		for i := 0; i < count && stop.Reason == intcode.StoppedStep; i++ {
+			stop = s.debugger.Step()
+		}
+		s.printStop(stop)
+	case "sb", "step-back":
+		n, err := numbers(0, 1)
+		if err != nil {
+			return true, err
+		}
+		count := 1
+		if len(n) == 1 {
+			count = n[0]
+		}
+		stop := intcode.Stop{}
+		for i := 0; i < count && stop.Reason == intcode.StoppedStep; i++ {
+			stop = s.debugger.StepBack()
+		}
+		s.printStop(stop)
+	case "rc", "reverse-continue":
+		s.printStop(s.debugger.ReverseContinue())
+	case "history":
+		n, err := numbers(0, 1)
+		if err != nil {
+			return true, err
+		}
+		if len(n) == 1 {
+			s.tape.SetHistoryLimit(n[0])
+		}
+		fmt.Printf("%d of %d instructions\n", s.tape.HistoryLength(), s.tape.HistoryLimit())
+	case "n", "next":
+		s.printStop(s.debugger.StepOver())
+	case "c", "continue":
+		s.printStop(s.debugger.Continue())
+	case "b", "break":
+		if len(args) == 2 && args[0] == "op" {
+			opcode, ok := intcode.OpcodeForMnemonic(args[1])
+			if !ok {
+				var err error
+				if opcode, err = strconv.Atoi(args[1]); err != nil {
+					return true, fmt.Errorf("unknown opcode: %s", args[1])
+				}
+			}
+			b, err := s.debugger.AddOpcodeBreakpoint(opcode)
+			if err != nil {
+				return true, err
+			}
+			fmt.Println("added", b)
+			break
+		}
+		n, err := numbers(1, 1)
+		if err != nil {
+			return true, err
+		}
+		fmt.Println("added", s.debugger.AddBreakpoint(n[0]))
+	case "w", "watch":
+		if len(args) < 1 || len(args) > 2 {
+			return true, fmt.Errorf("%s: wrong number of arguments", command)
+		}
+		n, err := intcode.ParseValues(args[0])
+		if err != nil {
+			return true, err
+		}
+		if len(n) != 1 {
+			return true, fmt.Errorf("%s: expected one address", command)
+		}
+		kind := "rw"
+		if len(args) == 2 {
+			kind = args[1]
+		}
+		read, write := strings.Contains(kind, "r"), strings.Contains(kind, "w")
+		if !read && !write {
+			return true, fmt.Errorf("watch kind must be r, w or rw")
+		}
+		fmt.Println("added", s.debugger.AddWatchpoint(n[0], read, write))
+	case "d", "delete":
+		n, err := numbers(1, 1)
+		if err != nil {
+			return true, err
+		}
+		if !s.debugger.Delete(n[0]) {
+			return true, fmt.Errorf("no breakpoint or watchpoint %d", n[0])
+		}
+	case "i", "info":
+		s.info()
+	case "x":
+		n, err := numbers(1, 2)
+		if err != nil {
+			return true, err
+		}
+		count := 1
+		if len(n) == 2 {
+			count = n[1]
+		}
+		for address := n[0]; address < n[0]+count; address++ {
+			value, err := s.tape.Peek(address)
+			if err != nil {
+				return true, err
+			}
+			fmt.Printf("%04d  %d\n", address, value)
+		}
+	case "poke":
+		n, err := numbers(2, 2)
+		if err != nil {
+			return true, err
+		}
+		if err := s.tape.Set(n[0], n[1]); err != nil {
+			return true, err
+		}
+	case "in":
+		n, err := numbers(1, math.MaxInt)
+		if err != nil {
+			return true, err
+		}
+		for _, x := range n {
+			s.tape.Input(x)
+		}
+	case "out":
+		output := s.tape.Output()
+		for !output.Empty() {
+			fmt.Println(output.Pop())
+		}
+		s.tape.ClearOutput()
+	case "l", "list":
+		n, err := numbers(0, 2)
+		if err != nil {
+			return true, err
+		}
+		address, count := s.tape.Cursor(), 10
+		if len(n) > 0 {
+			address = n[0]
+		}
+		if len(n) > 1 {
+			count = n[1]
+		}
+		for _, in := range s.debugger.List(address, count) {
+			marker := "  "
+			if in.Address == s.tape.Cursor() {
+				marker = "=>"
+			}
+			fmt.Println(marker, intcode.FormatLine(in))
+		}
+	case "save":
+		if len(args) != 1 {
+			return true, fmt.Errorf("%s: wrong number of arguments", command)
+		}
+		file, err := os.Create(args[0])
+		if err != nil {
+			return true, err
+		}
+		if err := s.tape.Save(file); err != nil {
+			file.Close()
+			return true, err
+		}
+		return true, file.Close()
+	case "load":
+		if len(args) != 1 {
+			return true, fmt.Errorf("%s: wrong number of arguments", command)
+		}
+		file, err := os.Open(args[0])
+		if err != nil {
+			return true, err
+		}
+		defer file.Close()
+		if err := s.tape.Load(file); err != nil {
+			return true, err
+		}
+		s.printCurrent()
+	case "h", "help":
+		fmt.Println(help)
+	case "q", "quit":
+		return false, nil
+	default:
+		return true, fmt.Errorf("unknown command %q (try help)", command)
+	}
+	return true, nil
+}
+
+func main() {
+	input := flag.String("input", "", "comma-separated values to queue as input")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: debugger [-input values] <tape file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := intcode.ReadTapeData(file)
+	file.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tape := intcode.CreateTapeCopy(data)
+	if *input != "" {
+		values, err := intcode.ParseValues(*input)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, x := range values {
+			tape.Input(x)
+		}
+	}
+
+	s := session{debugger: intcode.NewDebugger(&tape), tape: &tape}
+	s.printCurrent()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("(intcode) ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		more, err := s.execute(fields[0], fields[1:])
+		if err != nil {
+			fmt.Println(err)
+		}
+		if !more {
+			return
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"advent-2019/intcode"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	entries := flag.String("entry", "", "comma-separated extra addresses to start disassembling from")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: disasm [-entry addresses] <tape file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	data, err := intcode.ReadTapeData(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	entryPoints, err := intcode.ParseValues(*entries)
+	if err != nil {
+		log.Fatal("Invalid entry point: ", err)
+	}
+	listing := intcode.Disassemble(data, entryPoints...)
+	if err := intcode.WriteListing(os.Stdout, listing); err != nil {
+		log.Fatal(err)
+	}
+}
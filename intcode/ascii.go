@@ -0,0 +1,142 @@
+package intcode
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// IsASCII returns whether an output value is a character, rather than a number such as an answer
+func IsASCII(x int) bool {
+	return x >= 0 && x < 128
+}
+
+// ASCII runs a program which reads and writes text a character at a time. Input is written as
+// strings, and output is read back as text, with anything outside of the ASCII range (usually the
+// answer) kept apart from it.
+type ASCII struct {
+	tape   *Tape
+	text   strings.Builder
+	values []int
+	// echo, if set, is sent all output as it happens, instead of it being kept
+	echo Output
+	// transcript, if set, records the input and output of the session
+	transcript *Transcript
+}
+
+// NewASCII connects a tape's output to a new adapter. Input already queued on the tape is still read.
+func NewASCII(t *Tape) *ASCII {
+	a := &ASCII{tape: t}
+	t.ConnectOutput(a)
+	return a
+}
+
+// Tape returns the tape being run
+func (a *ASCII) Tape() *Tape {
+	return a.tape
+}
+
+// Write is the tape's output
+func (a *ASCII) Write(x int) error {
+	if a.transcript != nil {
+		a.transcript.addOutput(x)
+	}
+	if a.echo != nil {
+		return a.echo.Write(x)
+	}
+	if IsASCII(x) {
+		a.text.WriteByte(byte(x))
+	} else {
+		a.values = append(a.values, x)
+	}
+	return nil
+}
+
+// WriteString queues s to be read by the tape, a byte at a time
+func (a *ASCII) WriteString(s string) {
+	for i := 0; i < len(s); i++ {
+		a.tape.Input(int(s[i]))
+	}
+}
+
+// WriteLine queues s and a newline to be read by the tape
+func (a *ASCII) WriteLine(s string) {
+	a.WriteString(s)
+	a.tape.Input('\n')
+}
+
+// Run runs the tape until it halts or waits for more input than has been written. Waiting for
+// input isn't an error: write some and run it again.
+func (a *ASCII) Run() error {
+	err := a.tape.RunUntilHalt()
+	if err != nil && a.tape.WaitingForInput() && errors.Is(err, ErrInputExhausted) {
+		return nil
+	}
+	return err
+}
+
+// Halted returns whether the tape has halted
+func (a *ASCII) Halted() bool {
+	return a.tape.IsHalted()
+}
+
+// Text returns the text output since it was last called
+func (a *ASCII) Text() string {
+	s := a.text.String()
+	a.text.Reset()
+	return s
+}
+
+// ReadLine returns the next complete line of text output, without its newline, and removes it.
+// It returns false if no whole line has been output yet.
+func (a *ASCII) ReadLine() (string, bool) {
+	s := a.text.String()
+	end := strings.IndexByte(s, '\n')
+	if end == -1 {
+		return "", false
+	}
+	a.text.Reset()
+	a.text.WriteString(s[end+1:])
+	return s[:end], true
+}
+
+// Values returns the output values which weren't characters, since it was last called
+func (a *ASCII) Values() []int {
+	values := a.values
+	a.values = nil
+	return values
+}
+
+// Interact bridges the tape to a terminal, or anything else: output is written to w as it
+// happens, as WriterOutput does, and whenever the tape needs input a line is read from r. It
+// returns once the tape halts, or with ErrInputExhausted if r runs out while the tape still needs input.
+func (a *ASCII) Interact(r io.Reader, w io.Writer) error {
+	a.echo = WriterOutput(w)
+	defer func() {
+		a.echo = nil
+	}()
+
+	lines := bufio.NewReader(r)
+	for {
+		if err := a.Run(); err != nil {
+			return err
+		}
+		if a.Halted() {
+			return nil
+		}
+
+		line, err := lines.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return ErrInputExhausted
+			}
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if a.transcript != nil {
+			a.transcript.addInput(line)
+		}
+		a.WriteLine(line)
+	}
+}
@@ -0,0 +1,80 @@
+package intcode
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestASCII talks to namer a line at a time, keeping its answers apart from its text
+func TestASCII(t *testing.T) {
+	tape := CreateTapeCopy(namer)
+	a := NewASCII(&tape)
+	if err := a.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if text := a.Text(); text != "Name? " || a.Halted() {
+		t.Fatalf("got %q, expected a prompt", text)
+	}
+
+	a.WriteLine("ab")
+	if err := a.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if line, ok := a.ReadLine(); !ok || line != "ab" {
+		t.Errorf("got line %q (%v), expected \"ab\"", line, ok)
+	}
+	if _, ok := a.ReadLine(); ok {
+		t.Errorf("read a line before it was finished")
+	}
+	if values := a.Values(); !reflect.DeepEqual(values, []int{1002}) {
+		t.Errorf("got values %v, expected [1002]", values)
+	}
+	if text := a.Text(); text != "Name? " {
+		t.Errorf("got %q left over, expected the next prompt", text)
+	}
+
+	a.WriteLine("")
+	if err := a.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Halted() || !reflect.DeepEqual(a.Values(), []int{1000}) {
+		t.Errorf("expected namer to halt with 1000 after an empty line")
+	}
+}
+
+func TestASCIIInteract(t *testing.T) {
+	tape := CreateTapeCopy(namer)
+	var out bytes.Buffer
+	if err := NewASCII(&tape).Interact(strings.NewReader("ab\ncde\n\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Name? ab\n1002\nName? cde\n1003\nName? \n1000\n"
+	if out.String() != expected {
+		t.Errorf("got %q, expected %q", out.String(), expected)
+	}
+
+	// The last line doesn't need a newline, but running out of lines is an error
+	tape = CreateTapeCopy(namer)
+	if err := NewASCII(&tape).Interact(strings.NewReader("ab"), &out); !errors.Is(err, ErrInputExhausted) {
+		t.Errorf("got %v running out of input, expected %v", err, ErrInputExhausted)
+	}
+}
+
+// TestPromptInput checks that a prompt is written for each input, and asked again after a bad line
+func TestPromptInput(t *testing.T) {
+	var out bytes.Buffer
+	tape := CreateTapeCopy(largeCompare)
+	tape.ConnectInput(PromptInput(strings.NewReader("eight\n 7 \n"), &out, "> "))
+	if err := tape.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	if output := tape.PendingOutput(); !reflect.DeepEqual(output, []int{999}) {
+		t.Errorf("got %v, expected [999]", output)
+	}
+	if out.String() != "> Invalid input\n> " {
+		t.Errorf("got prompts %q", out.String())
+	}
+}
@@ -0,0 +1,343 @@
+package intcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// AssemblyError is returned when a line of assembly source cannot be assembled
+type AssemblyError struct {
+	Line int
+	Err  error
+}
+
+func (e *AssemblyError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *AssemblyError) Unwrap() error {
+	return e.Err
+}
+
+// asmLine is a parsed line of source which emits values onto the tape
+type asmLine struct {
+	line   int
+	opcode int
+	// args are the operand (or data) expressions, with their modes for instructions
+	args  []string
+	modes []int
+	data  bool
+}
+
+// symbol is a label or a constant
+type symbol struct {
+	line      int
+	expr      string
+	value     int
+	resolved  bool
+	resolving bool
+}
+
+type assembler struct {
+	symbols   map[string]*symbol
+	constants []string
+	lines     []asmLine
+	address   int
+}
+
+var mnemonics = func() map[string]int {
+	m := map[string]int{}
+	for opcode, info := range opcodes {
+		m[info.mnemonic] = opcode
+	}
+	return m
+}()
+
+// OpcodeForMnemonic returns the opcode for an instruction mnemonic such as "add"
+func OpcodeForMnemonic(mnemonic string) (int, bool) {
+	opcode, ok := mnemonics[mnemonic]
+	return opcode, ok
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || s == "rb" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || unicode.IsLetter(r) || (i > 0 && (unicode.IsDigit(r) || r == '.')) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *assembler) define(name string, s *symbol) error {
+	if !isIdentifier(name) {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	if existing, ok := a.symbols[name]; ok {
+		return fmt.Errorf("%s is already defined on line %d", name, existing.line)
+	}
+	a.symbols[name] = s
+	return nil
+}
+
+// parseOperand splits an operand into its mode and expression
+func parseOperand(operand string) (int, string, error) {
+	switch {
+	case strings.HasPrefix(operand, "#"):
+		return immediateMode, operand[1:], nil
+	case strings.HasPrefix(operand, "[") && strings.HasSuffix(operand, "]"):
+		return positionMode, operand[1 : len(operand)-1], nil
+	case operand == "rb":
+		return relativeMode, "0", nil
+	case strings.HasPrefix(operand, "rb+"), strings.HasPrefix(operand, "rb-"):
+		return relativeMode, operand[2:], nil
+	default:
+		return 0, "", fmt.Errorf("invalid operand %q (expected #imm, [pos] or rb+off)", operand)
+	}
+}
+
+func splitArgs(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	args := strings.Split(s, ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	return args
+}
+
+// parseLine handles a single line of source during the first pass
+func (a *assembler) parseLine(number int, text string) error {
+	if comment := strings.Index(text, ";"); comment != -1 {
+		text = text[:comment]
+	}
+	text = strings.TrimSpace(text)
+
+	// Disassembly listings start with the address, which is only there for reading
+	if fields := strings.Fields(text); len(fields) > 0 && isNumber(fields[0]) {
+		text = strings.TrimSpace(text[len(fields[0]):])
+	}
+
+	if fields := strings.Fields(text); len(fields) > 0 && strings.HasSuffix(fields[0], ":") {
+		name := strings.TrimSuffix(fields[0], ":")
+		if err := a.define(name, &symbol{line: number, value: a.address, resolved: true}); err != nil {
+			return err
+		}
+		text = strings.TrimSpace(text[len(fields[0]):])
+	}
+
+	if text == "" {
+		return nil
+	}
+
+	mnemonic := strings.Fields(text)[0]
+	rest := strings.TrimSpace(text[len(mnemonic):])
+
+	switch mnemonic {
+	case "const":
+		parts := strings.SplitN(rest, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("expected const NAME = value")
+		}
+		name := strings.TrimSpace(parts[0])
+		a.constants = append(a.constants, name)
+		return a.define(name, &symbol{line: number, expr: strings.TrimSpace(parts[1])})
+	case "data":
+		args := splitArgs(rest)
+		if len(args) == 0 {
+			return fmt.Errorf("data needs at least one value")
+		}
+		a.lines = append(a.lines, asmLine{line: number, args: args, data: true})
+		a.address += len(args)
+		return nil
+	}
+
+	opcode, ok := mnemonics[mnemonic]
+	if !ok {
+		return fmt.Errorf("unknown mnemonic %q", mnemonic)
+	}
+	info := opcodes[opcode]
+
+	operands := splitArgs(rest)
+	if len(operands) != info.paramCount {
+		return fmt.Errorf("%s takes %d operands, got %d", mnemonic, info.paramCount, len(operands))
+	}
+
+	line := asmLine{line: number, opcode: opcode}
+	for i, operand := range operands {
+		mode, expr, err := parseOperand(operand)
+		if err != nil {
+			return err
+		}
+		if mode == immediateMode && i == info.destination {
+			return fmt.Errorf("%s can't store to an immediate operand %q", mnemonic, operand)
+		}
+		line.modes = append(line.modes, mode)
+		line.args = append(line.args, expr)
+	}
+	a.lines = append(a.lines, line)
+	a.address += info.paramCount + 1
+	return nil
+}
+
+// lookup returns the value of a label or constant, evaluating constants on first use
+func (a *assembler) lookup(name string) (int, error) {
+	s, ok := a.symbols[name]
+	if !ok {
+		return 0, fmt.Errorf("undefined name %q", name)
+	}
+	if s.resolved {
+		return s.value, nil
+	}
+	if s.resolving {
+		return 0, fmt.Errorf("constant %s is defined in terms of itself", name)
+	}
+
+	s.resolving = true
+	value, err := a.eval(s.expr)
+	s.resolving = false
+	if err != nil {
+		return 0, fmt.Errorf("in constant %s (line %d): %w", name, s.line, err)
+	}
+	s.value, s.resolved = value, true
+	return value, nil
+}
+
+// eval evaluates an expression made of numbers, labels and constants joined by + and -
+func (a *assembler) eval(expr string) (int, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return 0, fmt.Errorf("missing value")
+	}
+
+	total := 0
+	sign := 1
+	for len(expr) > 0 {
+		if expr[0] == '+' || expr[0] == '-' {
+			if expr[0] == '-' {
+				sign = -sign
+			}
+			expr = strings.TrimSpace(expr[1:])
+			continue
+		}
+
+		end := strings.IndexAny(expr, "+-")
+		if end == -1 {
+			end = len(expr)
+		}
+		term := strings.TrimSpace(expr[:end])
+		expr = strings.TrimSpace(expr[end:])
+
+		var value int
+		var err error
+		if isNumber(term) {
+			value, err = strconv.Atoi(term)
+		} else if isIdentifier(term) {
+			value, err = a.lookup(term)
+		} else {
+			err = fmt.Errorf("invalid value %q", term)
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		total += sign * value
+		sign = 1
+	}
+	return total, nil
+}
+
+// emit evaluates a parsed line into tape values
+func (a *assembler) emit(line asmLine) ([]int, error) {
+	var values []int
+	instruction := line.opcode
+	scale := 100
+	if !line.data {
+		values = append(values, 0)
+	}
+
+	for i, arg := range line.args {
+		value, err := a.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if !line.data {
+			instruction += line.modes[i] * scale
+			scale *= 10
+		}
+	}
+
+	if !line.data {
+		values[0] = instruction
+	}
+	return values, nil
+}
+
+// Assemble reads intcode assembly source and returns the tape image it describes.
+//
+// Each line is an optional label ("loop:"), followed by an instruction, a data directive
+// ("data 1, 2, 3") or a constant ("const SIZE = 10"). Instructions use the mnemonics add, mul,
+// in, out, jnz, jz, lt, eq, arb and hlt, with operands written as #imm (immediate), [pos]
+// (position) or rb+off (relative). Values may be numbers, labels or constants, joined with
+// + and -. Comments start with ";", and a leading address (as written by WriteListing) is
+// ignored, so disassembly listings can be edited and assembled again.
+func Assemble(r io.Reader) ([]int, error) {
+	a := assembler{symbols: map[string]*symbol{}}
+
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		if err := a.parseLine(number, scanner.Text()); err != nil {
+			return nil, &AssemblyError{number, err}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Evaluate every constant, so mistakes are reported even if the constant is never used
+	for _, name := range a.constants {
+		if _, err := a.lookup(name); err != nil {
+			return nil, &AssemblyError{a.symbols[name].line, err}
+		}
+	}
+
+	data := make([]int, 0, a.address)
+	for _, line := range a.lines {
+		values, err := a.emit(line)
+		if err != nil {
+			return nil, &AssemblyError{line.line, err}
+		}
+		data = append(data, values...)
+	}
+	return data, nil
+}
+
+// FormatTapeData formats a tape image in the comma-separated form read by GetTapeData
+func FormatTapeData(data []int) string {
+	values := make([]string, len(data))
+	for i, x := range data {
+		values[i] = strconv.Itoa(x)
+	}
+	return strings.Join(values, ",")
+}
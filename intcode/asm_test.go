@@ -0,0 +1,69 @@
+package intcode
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestAssemble assembles a program using labels, constants, expressions and every operand mode,
+// and runs it
+func TestAssemble(t *testing.T) {
+	data, err := Assemble(strings.NewReader(`
+        const COUNT = 3
+        const END = table + COUNT ; constants may use labels
+start:  arb #END
+        add [table+1], #COUNT, rb-1
+        out rb-1
+        hlt
+table:  data 10, 20, 30
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// table is at 9, so END is 12
+	expected := []int{109, 12, 21001, 10, 3, -1, 204, -1, 99, 10, 20, 30}
+	if !reflect.DeepEqual(data, expected) {
+		t.Fatalf("got %v, expected %v", data, expected)
+	}
+
+	tape := CreateTapeCopy(data)
+	if err := tape.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	if output := tape.PendingOutput(); !reflect.DeepEqual(output, []int{23}) {
+		t.Errorf("got output %v, expected [23]", output)
+	}
+}
+
+var assemblyErrors = []struct {
+	name   string
+	source string
+	line   int
+}{
+	{"unknown mnemonic", "hlt\nfoo #1", 2},
+	{"wrong operand count", "add #1, #2", 1},
+	{"invalid operand", "out 1", 1},
+	{"immediate destination", "add #1, #2, #3", 1},
+	{"undefined name", "\n\njnz #1, #nowhere", 3},
+	{"duplicate label", "a: hlt\na: hlt", 2},
+	{"circular constant", "const A = B\nconst B = A\nhlt", 1},
+	{"empty data", "data", 1},
+}
+
+// TestAssemblyErrors checks that mistakes are reported as an *AssemblyError giving their line
+func TestAssemblyErrors(t *testing.T) {
+	for _, c := range assemblyErrors {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Assemble(strings.NewReader(c.source))
+			var asmErr *AssemblyError
+			if !errors.As(err, &asmErr) {
+				t.Fatalf("got error %v, expected an *AssemblyError", err)
+			}
+			if asmErr.Line != c.line {
+				t.Errorf("got error on line %d, expected line %d: %v", asmErr.Line, c.line, err)
+			}
+		})
+	}
+}
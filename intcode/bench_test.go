@@ -0,0 +1,288 @@
+package intcode
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// benchMode is how a benchmark runs its tapes
+type benchMode struct {
+	name    string
+	newTape func(program []int) func() Tape
+}
+
+var benchModes = []benchMode{
+	{"uncached", func(program []int) func() Tape {
+		return func() Tape {
+			t := CreateTapeCopy(program)
+			t.SetDecodeCache(false)
+			return t
+		}
+	}},
+	{"cached", func(program []int) func() Tape {
+		return func() Tape {
+			return CreateTapeCopy(program)
+		}
+	}},
+	// The program is compiled once, and every tape shares it
+	{"compiled", func(program []int) func() Tape {
+		return Compile(program).NewTape
+	}},
+}
+
+func mustAssemble(source string) []int {
+	data, err := Assemble(strings.NewReader(source))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// countdown sums the numbers from its input down to 1 in a tight loop
+var countdown = mustAssemble(`
+        in [n]
+loop:   add [sum], [n], [sum]
+        add [n], #-1, [n]
+        jnz [n], #loop
+        out [sum]
+        hlt
+n:      data 0
+sum:    data 0
+`)
+
+// rewriter switches one of its own instructions between add and mul on every pass,
+// so every pass invalidates the cached decoding
+var rewriter = mustAssemble(`
+        in [n]
+loop:
+op:     add [acc], #3, [acc]
+        mul [op], #-1, [tmp]
+        add [tmp], #2003, [op]
+        add [n], #-1, [n]
+        jnz [n], #loop
+        out [acc]
+        hlt
+n:      data 0
+acc:    data 1
+tmp:    data 0
+`)
+
+var day2Example = []int{1, 9, 10, 3, 2, 3, 11, 0, 99, 30, 40, 50}
+
+// day2Program builds a program shaped like a day 2 puzzle input: the noun and verb cells are added,
+// then a long run of additions and multiplications combines the total with the constants after
+// the halt, and the result is stored in cell 0
+func day2Program(steps int) []int {
+	constants := 4*(steps+2) + 1
+	data := []int{addOpcode, 0, 0, 3}
+	for i := 0; i < steps; i++ {
+		opcode := addOpcode
+		if i%3 == 2 {
+			opcode = multiplyOpcode
+		}
+		data = append(data, opcode, 3, constants+i%8, 3)
+	}
+	data = append(data, addOpcode, 3, constants+8, 0, haltOpcode)
+	return append(data, 1, 2, 3, 4, 5, 6, 7, 8, 0)
+}
+
+var day2Input = day2Program(36)
+
+// amplifier is a larger amplifier for day 7, which loops over the signal for longer the higher
+// its phase
+var amplifier = mustAssemble(`
+        in [phase]
+        in [signal]
+        add [phase], #20, [n]
+loop:   add [signal], [phase], [signal]
+        mul [signal], #3, [tmp]
+        lt [tmp], #1000000, [small]
+        jz [small], #next
+        add [tmp], #-2, [signal]
+next:   add [n], #-1, [n]
+        jnz [n], #loop
+        out [signal]
+        hlt
+phase:  data 0
+signal: data 0
+n:      data 0
+tmp:    data 0
+small:  data 0
+`)
+
+var day7Example = []int{3, 31, 3, 32, 1002, 32, 10, 32, 1001, 31, -2, 31, 1007, 31, 0, 33, 1002, 33, 7, 33, 1, 33, 31, 31, 1, 32, 31, 31, 4, 31, 99, 0, 0, 0}
+
+func runBench(tape Tape, input ...int) (Tape, error) {
+	for _, x := range input {
+		tape.Input(x)
+	}
+	return tape, tape.RunUntilHalt()
+}
+
+func benchLoop(program []int, n int) func(newTape func() Tape) ([]int, error) {
+	return func(newTape func() Tape) ([]int, error) {
+		tape, err := runBench(newTape(), n)
+		return tape.PendingOutput(), err
+	}
+}
+
+// day2Search tries every noun and verb which point inside a program, as day 2 does
+func day2Search(program []int) func(newTape func() Tape) ([]int, error) {
+	return func(newTape func() Tape) ([]int, error) {
+		var results []int
+		for noun := 0; noun < len(program); noun++ {
+			for verb := 0; verb < len(program); verb++ {
+				tape := newTape()
+				tape.Set(1, noun)
+				tape.Set(2, verb)
+				tape, err := runBench(tape)
+				if err != nil {
+					return nil, err
+				}
+				results = append(results, tape.First())
+			}
+		}
+		return results, nil
+	}
+}
+
+func permutations(values []int) [][]int {
+	if len(values) <= 1 {
+		return [][]int{values}
+	}
+	var all [][]int
+	for i, first := range values {
+		rest := append(append([]int(nil), values[:i]...), values[i+1:]...)
+		for _, p := range permutations(rest) {
+			all = append(all, append([]int{first}, p...))
+		}
+	}
+	return all
+}
+
+var phasePermutations = permutations([]int{0, 1, 2, 3, 4})
+
+// day7Sweep runs the amplifier chain for every ordering of phases, as day 7 does
+func day7Sweep(newTape func() Tape) ([]int, error) {
+	best := 0
+	for _, phases := range phasePermutations {
+		signal := 0
+		for _, phase := range phases {
+			tape, err := runBench(newTape(), phase, signal)
+			if err != nil {
+				return nil, err
+			}
+			output := tape.PendingOutput()
+			signal = output[len(output)-1]
+		}
+		if signal > best {
+			best = signal
+		}
+	}
+	return []int{best}, nil
+}
+
+// benchWorkload is a program run from start to halt, with the tapes made by newTape
+type benchWorkload struct {
+	name    string
+	program []int
+	run     func(newTape func() Tape) ([]int, error)
+}
+
+var benchWorkloads = []benchWorkload{
+	{"Day2Search", day2Example, day2Search(day2Example)},
+	{"Day2Input", day2Input, day2Search(day2Input)},
+	{"Day7Sweep", day7Example, day7Sweep},
+	{"Day7Amplifiers", amplifier, day7Sweep},
+	{"Countdown", countdown, benchLoop(countdown, 10000)},
+	{"SelfModifying", rewriter, benchLoop(rewriter, 10000)},
+}
+
+// BenchmarkWorkloads runs each workload in each mode, after checking that every mode gives the
+// same answer
+func BenchmarkWorkloads(b *testing.B) {
+	for _, w := range benchWorkloads {
+		expected, err := w.run(benchModes[0].newTape(w.program))
+		if err != nil {
+			b.Fatalf("%s: %v", w.name, err)
+		}
+		for _, m := range benchModes {
+			newTape := m.newTape(w.program)
+			actual, err := w.run(newTape)
+			if err != nil || !reflect.DeepEqual(actual, expected) {
+				b.Fatalf("%s %s: got %v (%v), expected %v", w.name, m.name, actual, err, expected)
+			}
+			b.Run(w.name+"/"+m.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for n := 0; n < b.N; n++ {
+					if _, err := w.run(newTape); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// stringDecode decodes an instruction the way the tape used to, by formatting the value and
+// picking out its digits, for comparison with decodeValue
+func stringDecode(value int) (opcode int, modes [3]int) {
+	s := strconv.Itoa(value)
+	digits := s
+	if len(digits) > 2 {
+		digits = digits[len(digits)-2:]
+	}
+	opcode, _ = strconv.Atoi(digits)
+	for i := range modes {
+		if pos := len(s) - i - 3; pos >= 0 {
+			modes[i], _ = strconv.Atoi(s[pos : pos+1])
+		}
+	}
+	return opcode, modes
+}
+
+var decodeValues = []int{1, 2, 1001, 1002, 3, 203, 104, 1105, 1106, 21107, 1008, 109, 22201, 99}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, value := range decodeValues {
+		opcode, modes := stringDecode(value)
+		d, err := decodeValue(value)
+		if err != nil || int(d.opcode) != opcode {
+			b.Fatalf("decoders disagree on %d", value)
+		}
+		for i := 0; i < int(d.paramCount); i++ {
+			if int(d.modes[i]) != modes[i] {
+				b.Fatalf("decoders disagree on %d", value)
+			}
+		}
+	}
+
+	b.Run("string", func(b *testing.B) {
+		b.ReportAllocs()
+		sum := 0
+		for n := 0; n < b.N; n++ {
+			for _, value := range decodeValues {
+				opcode, modes := stringDecode(value)
+				sum += opcode + modes[0] + modes[1] + modes[2]
+			}
+		}
+		if sum < 0 {
+			b.Fatal("unreachable")
+		}
+	})
+	b.Run("arithmetic", func(b *testing.B) {
+		b.ReportAllocs()
+		sum := 0
+		for n := 0; n < b.N; n++ {
+			for _, value := range decodeValues {
+				d, _ := decodeValue(value)
+				sum += int(d.opcode) + int(d.modes[0]) + int(d.modes[1]) + int(d.modes[2])
+			}
+		}
+		if sum < 0 {
+			b.Fatal("unreachable")
+		}
+	})
+}
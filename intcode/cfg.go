@@ -0,0 +1,316 @@
+package intcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// EdgeKind is how control passes from one basic block to another
+type EdgeKind int
+
+const (
+	// FallThrough is control running off the end of a block into the next one
+	FallThrough EdgeKind = iota
+	// Jump is a jump to an immediate target
+	Jump
+	// Call is a jump to a function, made after storing the address to return to
+	Call
+	// CallReturn links a call to the address the function returns to
+	CallReturn
+	// Return is a jump through a return address stored relative to the relative base
+	Return
+)
+
+var edgeKindNames = map[EdgeKind]string{
+	FallThrough: "fallthrough",
+	Jump:        "jump",
+	Call:        "call",
+	CallReturn:  "call return",
+	Return:      "return",
+}
+
+func (k EdgeKind) String() string {
+	return edgeKindNames[k]
+}
+
+// Edge is a possible transfer of control between the blocks starting at From and To
+type Edge struct {
+	From, To int
+	Kind     EdgeKind
+}
+
+// BasicBlock is a straight run of instructions which is only entered at the top and only left at the bottom
+type BasicBlock struct {
+	// Start is the address of the first instruction, and End is the address after the last one
+	Start, End   int
+	Instructions []Instruction
+	// Function is the entry address of the function the block belongs to. Code reached from the
+	// entry points rather than through a call belongs to function 0.
+	Function int
+	// ComputedJump is set if the block ends with a jump whose target is read from memory.
+	// Return is set if that jump always jumps, reads the target relative to the relative base,
+	// and follows an arb, which is how functions return.
+	ComputedJump bool
+	Return       bool
+	Successors   []Edge
+	Predecessors []Edge
+}
+
+// Last returns the final instruction of the block
+func (b *BasicBlock) Last() Instruction {
+	return b.Instructions[len(b.Instructions)-1]
+}
+
+// Graph is the control-flow graph of a tape image
+type Graph struct {
+	// Blocks are the reachable basic blocks, in address order
+	Blocks []*BasicBlock
+	// Functions are the entry addresses of the functions found, in address order
+	Functions []int
+	Edges     []Edge
+	blocks    map[int]*BasicBlock
+	size      int
+}
+
+// Span is a range of addresses, from Start up to but not including End
+type Span struct {
+	Start, End int
+}
+
+// Block returns the block starting at address, or nil if there isn't one
+func (g *Graph) Block(address int) *BasicBlock {
+	return g.blocks[address]
+}
+
+// Unreached returns the ranges of the tape image which no block covers, which hold data or code
+// only reachable through computed jumps
+func (g *Graph) Unreached() []Span {
+	var spans []Span
+	address := 0
+	for _, b := range g.Blocks {
+		if b.Start > address {
+			spans = append(spans, Span{address, b.Start})
+		}
+		address = b.End
+	}
+	if address < g.size {
+		spans = append(spans, Span{address, g.size})
+	}
+	return spans
+}
+
+// isCall returns whether a jump is a function call: an unconditional jump to an immediate target,
+// straight after an instruction storing the address after the jump relative to the relative base
+func isCall(previous Instruction, hasPrevious bool, jump Instruction) bool {
+	always, _ := isUnconditionalJump(jump)
+	return always && hasPrevious && jump.Operands[1].Mode == immediateMode &&
+		storesReturnAddress(previous, jump.Address+jump.Len())
+}
+
+// isReturn returns whether a jump is a function return: an unconditional jump through an address
+// relative to the relative base, straight after an arb which drops the function's frame
+func isReturn(previous Instruction, hasPrevious bool, jump Instruction) bool {
+	always, _ := isUnconditionalJump(jump)
+	return always && hasPrevious && jump.Operands[1].Mode == relativeMode &&
+		previous.Opcode == relativeAdjustOpcode
+}
+
+// BuildCFG builds the control-flow graph of a tape image. Code is found the same way Disassemble
+// finds it, walking from address 0 and any extra entry points given, so anything only reachable
+// through a computed jump is left out. Calls and returns are recognised by the idiom compiled
+// programs use: the return address is stored relative to the relative base, the function is
+// jumped to unconditionally, and it returns by moving the relative base back with arb and
+// jumping through the stored address.
+func BuildCFG(data []int, entries ...int) *Graph {
+	code := map[int]Instruction{}
+	var addresses []int
+	for _, in := range Disassemble(data, entries...) {
+		if !in.IsData() {
+			code[in.Address] = in
+			addresses = append(addresses, in.Address)
+		}
+	}
+
+	// Blocks start at entry points, jump targets, and after any jump or halt
+	leaders := map[int]bool{0: true}
+	for _, entry := range entries {
+		leaders[entry] = true
+	}
+	for _, address := range addresses {
+		in := code[address]
+		switch in.Opcode {
+		case jumpIfTrueOpcode, jumpIfFalseOpcode:
+			if target := in.Operands[1]; target.Mode == immediateMode {
+				leaders[target.Value] = true
+			}
+			leaders[address+in.Len()] = true
+		case haltOpcode:
+			leaders[address+in.Len()] = true
+		}
+	}
+
+	g := &Graph{blocks: map[int]*BasicBlock{}, size: len(data)}
+	var current *BasicBlock
+	for _, address := range addresses {
+		if current == nil || leaders[address] || current.End != address {
+			current = &BasicBlock{Start: address, End: address}
+			g.Blocks = append(g.Blocks, current)
+			g.blocks[address] = current
+		}
+		in := code[address]
+		current.Instructions = append(current.Instructions, in)
+		current.End = address + in.Len()
+	}
+
+	// returnsTo maps each function to the addresses its calls return to
+	returnsTo := map[int][]int{}
+	addEdge := func(from, to int, kind EdgeKind) {
+		if g.blocks[to] == nil {
+			return
+		}
+		g.Edges = append(g.Edges, Edge{from, to, kind})
+	}
+	for _, b := range g.Blocks {
+		last := b.Last()
+		next := b.End
+		switch last.Opcode {
+		case haltOpcode:
+		case jumpIfTrueOpcode, jumpIfFalseOpcode:
+			always, ever := isUnconditionalJump(last)
+			target := last.Operands[1]
+			var previous Instruction
+			if len(b.Instructions) > 1 {
+				previous = b.Instructions[len(b.Instructions)-2]
+			}
+			switch {
+			case !ever:
+			case isCall(previous, len(b.Instructions) > 1, last):
+				addEdge(b.Start, target.Value, Call)
+				addEdge(b.Start, next, CallReturn)
+				returnsTo[target.Value] = append(returnsTo[target.Value], next)
+			case target.Mode == immediateMode:
+				addEdge(b.Start, target.Value, Jump)
+			default:
+				b.ComputedJump = true
+				b.Return = isReturn(previous, len(b.Instructions) > 1, last)
+			}
+			if !always {
+				addEdge(b.Start, next, FallThrough)
+			}
+		default:
+			addEdge(b.Start, next, FallThrough)
+		}
+	}
+
+	for function := range returnsTo {
+		if g.blocks[function] != nil {
+			g.Functions = append(g.Functions, function)
+		}
+	}
+	sort.Ints(g.Functions)
+
+	// Blocks belong to the first function which reaches them without following a call.
+	// Returns are linked back to every address the function containing them is called from.
+	roots := append([]int{0}, entries...)
+	owned := map[*BasicBlock]bool{}
+	g.assignFunctions(0, roots, owned, nil)
+	for _, function := range g.Functions {
+		g.assignFunctions(function, []int{function}, owned, returnsTo[function])
+	}
+
+	for _, edge := range g.Edges {
+		g.blocks[edge.From].Successors = append(g.blocks[edge.From].Successors, edge)
+		g.blocks[edge.To].Predecessors = append(g.blocks[edge.To].Predecessors, edge)
+	}
+	return g
+}
+
+// assignFunctions walks the blocks reachable from roots without following calls, giving any
+// which don't have a function yet to function, and adding return edges from the returns found
+func (g *Graph) assignFunctions(function int, roots []int, owned map[*BasicBlock]bool, returns []int) {
+	seen := map[*BasicBlock]bool{}
+	work := append([]int(nil), roots...)
+	for len(work) > 0 {
+		b := g.blocks[work[len(work)-1]]
+		work = work[:len(work)-1]
+		if b == nil || seen[b] {
+			continue
+		}
+		seen[b] = true
+
+		if !owned[b] {
+			owned[b] = true
+			b.Function = function
+		}
+		if b.Return {
+			for _, address := range returns {
+				// A call at the very end of the image returns past it, where there is no block
+				if g.blocks[address] != nil {
+					g.Edges = append(g.Edges, Edge{b.Start, address, Return})
+				}
+			}
+		}
+		for _, edge := range g.Edges {
+			if edge.From == b.Start && edge.Kind != Call && edge.Kind != Return {
+				work = append(work, edge.To)
+			}
+		}
+	}
+}
+
+var dotEdgeStyles = map[EdgeKind]string{
+	FallThrough: "",
+	Jump:        ` [color="blue"]`,
+	Call:        ` [color="darkgreen", label="call"]`,
+	CallReturn:  ` [style="dashed"]`,
+	Return:      ` [color="red", style="dashed", label="ret"]`,
+}
+
+// dotEscape escapes a string for use inside a quoted DOT label
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// WriteDot writes the graph in Graphviz DOT format. Each function is drawn as a cluster, blocks
+// ending in computed jumps are highlighted, and edges are styled by kind.
+func (g *Graph) WriteDot(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph intcode {")
+	fmt.Fprintln(bw, `  node [shape="box", fontname="monospace"];`)
+
+	byFunction := map[int][]*BasicBlock{}
+	var functions []int
+	for _, b := range g.Blocks {
+		if _, ok := byFunction[b.Function]; !ok {
+			functions = append(functions, b.Function)
+		}
+		byFunction[b.Function] = append(byFunction[b.Function], b)
+	}
+
+	for _, function := range functions {
+		fmt.Fprintf(bw, "  subgraph cluster_%d {\n", function)
+		fmt.Fprintf(bw, "    label=\"function %04d\";\n", function)
+		for _, b := range byFunction[function] {
+			var label strings.Builder
+			for _, in := range b.Instructions {
+				label.WriteString(dotEscape(FormatLine(in)))
+				label.WriteString(`\l`)
+			}
+			style := ""
+			if b.ComputedJump && !b.Return {
+				style = `, color="red"`
+			}
+			fmt.Fprintf(bw, "    b%d [label=\"%s\"%s];\n", b.Start, label.String(), style)
+		}
+		fmt.Fprintln(bw, "  }")
+	}
+
+	for _, edge := range g.Edges {
+		fmt.Fprintf(bw, "  b%d -> b%d%s;\n", edge.From, edge.To, dotEdgeStyles[edge.Kind])
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
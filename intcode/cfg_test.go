@@ -0,0 +1,66 @@
+package intcode
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// caller stores its input, passes it to double as an argument and prints the result. The add
+// and jump after the call look like a call, but don't store anything on the stack.
+var caller = mustAssemble(`
+        arb #stack
+        in [x]
+        add [x], #0, rb+1
+        add #ret, #0, rb
+        jnz #1, #double
+ret:    out [x]
+        add #skip, [x], [x]
+        jnz #1, #skip
+skip:   hlt
+double: arb #2
+        mul rb-1, #2, [x]
+        arb #-2
+        jnz #1, rb
+x:      data 0
+stack:  data 0
+`)
+
+// callAtEnd ends with a call, so the call returns past the end of the image
+var callAtEnd = []int{1105, 1, 8, 109, 0, 2106, 0, 0, 21101, 15, 0, 0, 1105, 1, 3}
+
+func TestBuildCFG(t *testing.T) {
+	g := BuildCFG(caller)
+	if !reflect.DeepEqual(g.Functions, []int{25}) {
+		t.Errorf("got functions %v, expected [25]", g.Functions)
+	}
+
+	expected := []Edge{{0, 25, Call}, {0, 15, CallReturn}, {15, 24, Jump}, {25, 15, Return}}
+	if !reflect.DeepEqual(g.Edges, expected) {
+		t.Errorf("got edges %v, expected %v", g.Edges, expected)
+	}
+	if b := g.Block(25); b == nil || b.Function != 25 || !b.Return {
+		t.Errorf("got function block %+v, expected a return in function 25", b)
+	}
+	for _, address := range []int{0, 15, 24} {
+		if b := g.Block(address); b == nil || b.Function != 0 {
+			t.Errorf("got block %+v at %d, expected one in function 0", b, address)
+		}
+	}
+	if spans := g.Unreached(); !reflect.DeepEqual(spans, []Span{{36, 38}}) {
+		t.Errorf("got unreached %v, expected the data at the end", spans)
+	}
+}
+
+func TestBuildCFGCallAtEnd(t *testing.T) {
+	g := BuildCFG(callAtEnd, 0)
+	for _, edge := range g.Edges {
+		if edge.Kind == Return {
+			t.Errorf("got return edge %v past the end of the image", edge)
+		}
+	}
+	var out bytes.Buffer
+	if err := WriteDecompiled(&out, callAtEnd, 0); err != nil {
+		t.Fatal(err)
+	}
+}
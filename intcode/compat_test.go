@@ -0,0 +1,495 @@
+package intcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// exampleCase is an example program from one of the puzzles, with the input it is given and the
+// answer the puzzle gives for it
+type exampleCase struct {
+	name    string
+	program []int
+	input   []int
+	// check returns the answer from a halted tape
+	check    func(t *Tape) interface{}
+	expected interface{}
+}
+
+func memoryAt(address int) func(t *Tape) interface{} {
+	return func(t *Tape) interface{} {
+		value, _ := t.Peek(address)
+		return value
+	}
+}
+
+func allOutput(t *Tape) interface{} {
+	return t.PendingOutput()
+}
+
+func mustParse(s string) []int {
+	data, err := ReadTapeData(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+var largeCompare = mustParse("3,21,1008,21,8,20,1005,20,22,107,8,21,20,1006,20,31,1106,0,36,98,0,0,1002,21,125,20,4,20,1105,1,46,104,999,1105,1,46,1101,1000,1,20,4,20,1105,1,46,98,99")
+
+var quine = []int{109, 1, 204, -1, 1001, 100, 1, 100, 1008, 100, 16, 101, 1006, 101, 0, 99}
+
+var exampleCases = []exampleCase{
+	// day 2: add, multiply and halt with position mode only
+	{"day2 example", []int{1, 9, 10, 3, 2, 3, 11, 0, 99, 30, 40, 50}, nil, memoryAt(0), 3500},
+	{"day2 add", []int{1, 0, 0, 0, 99}, nil, memoryAt(0), 2},
+	{"day2 multiply", []int{2, 3, 0, 3, 99}, nil, memoryAt(3), 6},
+	{"day2 multiply past program", []int{2, 4, 4, 5, 99, 0}, nil, memoryAt(5), 9801},
+	{"day2 self-modifying", []int{1, 1, 1, 4, 99, 5, 6, 0, 99}, nil, memoryAt(0), 30},
+
+	// day 5: input, output, parameter modes, jumps and comparisons
+	{"day5 echo", []int{3, 0, 4, 0, 99}, []int{42}, allOutput, []int{42}},
+	{"day5 immediate mode", []int{1002, 4, 3, 4, 33}, nil, memoryAt(4), 99},
+	{"day5 negative values", []int{1101, 100, -1, 4, 0}, nil, memoryAt(4), 99},
+	{"day5 equal to 8, position", []int{3, 9, 8, 9, 10, 9, 4, 9, 99, -1, 8}, []int{8}, allOutput, []int{1}},
+	{"day5 equal to 8, position (false)", []int{3, 9, 8, 9, 10, 9, 4, 9, 99, -1, 8}, []int{7}, allOutput, []int{0}},
+	{"day5 less than 8, position", []int{3, 9, 7, 9, 10, 9, 4, 9, 99, -1, 8}, []int{5}, allOutput, []int{1}},
+	{"day5 equal to 8, immediate", []int{3, 3, 1108, -1, 8, 3, 4, 3, 99}, []int{8}, allOutput, []int{1}},
+	{"day5 less than 8, immediate", []int{3, 3, 1107, -1, 8, 3, 4, 3, 99}, []int{9}, allOutput, []int{0}},
+	{"day5 jump, position", []int{3, 12, 6, 12, 15, 1, 13, 14, 13, 4, 13, 99, -1, 0, 1, 9}, []int{0}, allOutput, []int{0}},
+	{"day5 jump, immediate", []int{3, 3, 1105, -1, 9, 1101, 0, 0, 12, 4, 12, 99, 1}, []int{5}, allOutput, []int{1}},
+	{"day5 compare below 8", largeCompare, []int{7}, allOutput, []int{999}},
+	{"day5 compare equal to 8", largeCompare, []int{8}, allOutput, []int{1000}},
+	{"day5 compare above 8", largeCompare, []int{9}, allOutput, []int{1001}},
+
+	// day 9: relative mode and large numbers
+	{"day9 quine", quine, nil, allOutput, quine},
+	{"day9 large multiply", []int{1102, 34915192, 34915192, 7, 4, 7, 99, 0}, nil, allOutput, []int{1219070632396864}},
+	{"day9 large value", []int{104, 1125899906842624, 99}, nil, allOutput, []int{1125899906842624}},
+}
+
+// TestExamples runs the puzzle examples in every mode
+func TestExamples(t *testing.T) {
+	for _, c := range exampleCases {
+		for _, m := range benchModes {
+			t.Run(c.name+"/"+m.name, func(t *testing.T) {
+				tape := m.newTape(c.program)()
+				for _, x := range c.input {
+					tape.Input(x)
+				}
+				if err := tape.RunUntilHalt(); err != nil {
+					t.Fatal(err)
+				}
+				if actual := c.check(&tape); !reflect.DeepEqual(actual, c.expected) {
+					t.Errorf("got %v, expected %v", actual, c.expected)
+				}
+			})
+		}
+	}
+}
+
+// overflowing multiplies 2^62 by 4 twice and outputs both results, which don't fit in an int
+var overflowing = []int{1102, 4611686018427387904, 4, 13, 1002, 13, 4, 14, 4, 13, 4, 14, 99, 0, 0}
+
+var wordModeCases = []struct {
+	name     string
+	mode     WordMode
+	expected []string
+	err      error
+}{
+	{"wrap", WrapWords, []string{"0", "0"}, nil},
+	{"checked", CheckedWords, nil, ErrOverflow},
+	{"big", BigWords, []string{"18446744073709551616", "73786976294838206464"}, nil},
+}
+
+// TestWordModes runs the overflowing program in each word mode
+func TestWordModes(t *testing.T) {
+	for _, c := range wordModeCases {
+		t.Run(c.name, func(t *testing.T) {
+			tape := CreateTapeCopy(overflowing)
+			tape.SetWordMode(c.mode)
+			var output []string
+			tape.ConnectOutput(BigOutputFunc(func(x *big.Int) error {
+				output = append(output, x.String())
+				return nil
+			}))
+			if err := tape.RunUntilHalt(); !errors.Is(err, c.err) {
+				t.Fatalf("got error %v, expected %v", err, c.err)
+			}
+			if !reflect.DeepEqual(output, c.expected) {
+				t.Errorf("got %v, expected %v", output, c.expected)
+			}
+
+			var saved bytes.Buffer
+			if err := tape.Save(&saved); err != nil {
+				t.Fatal(err)
+			}
+			loaded := CreateTapeCopy(nil)
+			if err := loaded.Load(&saved); err != nil {
+				t.Fatal(err)
+			}
+			if loaded.WordMode() != c.mode {
+				t.Errorf("loaded word mode %v, expected %v", loaded.WordMode(), c.mode)
+			}
+		})
+	}
+}
+
+// runaway loops forever: it adds 1 to a counter and jumps back to the start
+var runaway = []int{1001, 5, 1, 5, 1105, 1, 0}
+
+var runawayCases = []struct {
+	name    string
+	limit   int
+	timeout time.Duration
+	// cursor and instructions are where the tape should be abandoned, if it has a limit
+	cursor, instructions int
+}{
+	{"instruction limit", 1001, 0, 4, 1001},
+	{"timeout", 0, 10 * time.Millisecond, 0, 0},
+}
+
+// TestRunaway abandons the runaway program with an instruction limit or a timeout, in every mode
+func TestRunaway(t *testing.T) {
+	for _, c := range runawayCases {
+		for _, m := range benchModes {
+			t.Run(c.name+"/"+m.name, func(t *testing.T) {
+				tape := m.newTape(runaway)()
+				tape.SetInstructionLimit(c.limit)
+				ctx := context.Background()
+				if c.timeout > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, c.timeout)
+					defer cancel()
+				}
+
+				var limitErr *LimitError
+				if err := tape.RunContext(ctx); !errors.As(err, &limitErr) {
+					t.Fatalf("expected a limit error, got %v", err)
+				}
+				if limitErr.Instructions != tape.InstructionCount() {
+					t.Errorf("error says %d instructions, tape says %d", limitErr.Instructions, tape.InstructionCount())
+				}
+				if c.timeout > 0 {
+					if !errors.Is(limitErr, context.DeadlineExceeded) {
+						t.Errorf("got %v, expected the deadline to be exceeded", limitErr)
+					}
+				} else if limitErr.Cursor != c.cursor || limitErr.Instructions != c.instructions {
+					t.Errorf("stopped at %d after %d instructions, expected %d after %d", limitErr.Cursor, limitErr.Instructions, c.cursor, c.instructions)
+				}
+			})
+		}
+	}
+}
+
+// amplifierCase is a day 7 program, with the phases that produce the highest signal
+type amplifierCase struct {
+	name     string
+	program  []int
+	phases   []int
+	feedback bool
+	expected int
+}
+
+var amplifierCases = []amplifierCase{
+	{"day7 chain 1", []int{3, 15, 3, 16, 1002, 16, 10, 16, 1, 16, 15, 15, 4, 15, 99, 0, 0}, []int{4, 3, 2, 1, 0}, false, 43210},
+	{"day7 chain 2", []int{3, 23, 3, 24, 1002, 24, 10, 24, 1002, 23, -1, 23, 101, 5, 23, 23, 1, 24, 23, 23, 4, 23, 99, 0, 0}, []int{0, 1, 2, 3, 4}, false, 54321},
+	{"day7 chain 3", []int{3, 31, 3, 32, 1002, 32, 10, 32, 1001, 31, -2, 31, 1007, 31, 0, 33, 1002, 33, 7, 33, 1, 33, 31, 31, 1, 32, 31, 31, 4, 31, 99, 0, 0, 0}, []int{1, 0, 4, 3, 2}, false, 65210},
+	{"day7 feedback 1", []int{3, 26, 1001, 26, -4, 26, 3, 27, 1002, 27, 2, 27, 1, 27, 26, 27, 4, 27, 1001, 28, -1, 28, 1005, 28, 6, 99, 0, 0, 5}, []int{9, 8, 7, 6, 5}, true, 139629729},
+	{"day7 feedback 2", []int{3, 52, 1001, 52, -5, 52, 3, 53, 1, 52, 56, 54, 1007, 54, 5, 55, 1005, 55, 26, 1001, 54, -5, 54, 1105, 1, 12, 1, 53, 54, 53, 1008, 54, 0, 55, 1001, 55, 1, 55, 2, 53, 55, 53, 4, 53, 1001, 56, -1, 56, 1005, 56, 6, 99, 0, 0, 0, 0, 10}, []int{9, 7, 8, 5, 6}, true, 18216},
+}
+
+// maxRounds is how many times each amplifier is run in turn before giving up
+const maxRounds = 1000
+
+// runAmplifiersInTurn runs the amplifiers on one goroutine, linked by queues, running each
+// until it needs input before moving on to the next, as day 11 and 13 drive their tapes
+func runAmplifiersInTurn(c amplifierCase) (int, error) {
+	queues := make([]*Queue, len(c.phases))
+	for i, phase := range c.phases {
+		queues[i] = &Queue{}
+		queues[i].Push(phase)
+	}
+	queues[0].Push(0)
+
+	amps := make([]Tape, len(c.phases))
+	for i := range amps {
+		amps[i] = CreateTapeCopy(c.program)
+		amps[i].ConnectInput(queues[i])
+		if c.feedback || i < len(amps)-1 {
+			amps[i].ConnectOutput(queues[(i+1)%len(amps)])
+		}
+	}
+
+	for round := 0; !amps[len(amps)-1].IsHalted(); round++ {
+		if round == maxRounds {
+			return 0, fmt.Errorf("still running after %d rounds", maxRounds)
+		}
+		for i := range amps {
+			err := amps[i].RunUntilHalt()
+			if err != nil && !amps[i].WaitingForInput() {
+				return 0, err
+			}
+		}
+	}
+
+	if c.feedback {
+		values := queues[0].Values()
+		return values[len(values)-1], nil
+	}
+	output := amps[len(amps)-1].PendingOutput()
+	return output[len(output)-1], nil
+}
+
+// runAmplifiersConcurrently runs each amplifier on its own goroutine, linked by channels, as day 7 does
+func runAmplifiersConcurrently(c amplifierCase) (int, error) {
+	channels := make([]chan int, len(c.phases))
+	for i := range channels {
+		// Buffered, so the last amplifier's final signal can be sent after the first has halted
+		channels[i] = make(chan int, 2)
+		channels[i] <- c.phases[i]
+	}
+	channels[0] <- 0
+
+	errs := make([]error, len(c.phases))
+	var wg sync.WaitGroup
+	for i := range c.phases {
+		t := CreateTapeCopy(c.program)
+		t.ConnectInput(ChanInput(channels[i]))
+		t.ConnectOutput(ChanOutput(channels[(i+1)%len(channels)]))
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = t.RunUntilHalt()
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return 0, err
+		}
+	}
+	return <-channels[0], nil
+}
+
+// runAmplifierNetwork runs the amplifiers as a Network, with the given scheduler
+func runAmplifierNetwork(scheduler Scheduler) func(c amplifierCase) (int, error) {
+	return func(c amplifierCase) (int, error) {
+		network := NewNetwork()
+		for _, phase := range c.phases {
+			t := CreateTapeCopy(c.program)
+			network.Send(network.Add(&t), phase)
+		}
+		for i := 0; i < len(c.phases)-1; i++ {
+			network.Link(i, i+1)
+		}
+		if c.feedback {
+			network.Link(len(c.phases)-1, 0)
+		}
+		network.Send(0, 0)
+
+		if err := network.Run(scheduler); err != nil {
+			return 0, err
+		}
+		signals := network.Outputs(len(c.phases) - 1)
+		return signals[len(signals)-1], nil
+	}
+}
+
+// amplifierRunners are the ways the amplifiers can be linked together and run
+var amplifierRunners = []struct {
+	name string
+	run  func(c amplifierCase) (int, error)
+}{
+	{"in turn", runAmplifiersInTurn},
+	{"concurrently", runAmplifiersConcurrently},
+	{"network, round robin", runAmplifierNetwork(RoundRobin)},
+	{"network, concurrent", runAmplifierNetwork(Concurrent)},
+}
+
+// TestAmplifiers runs the day 7 examples in each of the ways amplifiers can be run
+func TestAmplifiers(t *testing.T) {
+	for _, c := range amplifierCases {
+		for _, r := range amplifierRunners {
+			t.Run(c.name+"/"+r.name, func(t *testing.T) {
+				signal, err := r.run(c)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if signal != c.expected {
+					t.Errorf("got %d, expected %d", signal, c.expected)
+				}
+			})
+		}
+	}
+}
+
+var schedulers = []struct {
+	name      string
+	scheduler Scheduler
+}{
+	{"round robin", RoundRobin},
+	{"concurrent", Concurrent},
+}
+
+// echo reads a value, outputs it, and halts
+var echo = []int{3, 0, 4, 0, 99}
+
+// TestNetworkDeadlock links two echo machines to each other without giving either any input,
+// which should deadlock
+func TestNetworkDeadlock(t *testing.T) {
+	for _, s := range schedulers {
+		t.Run(s.name, func(t *testing.T) {
+			network := NewNetwork()
+			for i := 0; i < 2; i++ {
+				tape := CreateTapeCopy(echo)
+				network.Add(&tape)
+			}
+			network.Link(0, 1)
+			network.Link(1, 0)
+			if err := network.Run(s.scheduler); !errors.Is(err, ErrDeadlock) {
+				t.Errorf("got %v, expected %v", err, ErrDeadlock)
+			}
+		})
+	}
+}
+
+// ring is a NIC program for a network of ringSize machines. Machine 0 starts by sending a packet
+// to machine 1, and each machine passes the packets it gets on to the next, adding 1 to X. The last
+// machine sends them to the NAT.
+const ringSize = 50
+
+var ring = mustAssemble(fmt.Sprintf(`
+        const SIZE = %d
+        in [addr]
+        jnz [addr], #wait
+        out #1
+        out #0
+        out #42
+wait:   in [x]
+        eq [x], #-1, [t]
+        jnz [t], #wait
+        in [y]
+        add [addr], #1, [next]
+        eq [next], #SIZE, [t]
+        jz [t], #send
+        add #255, #0, [next]
+send:   out [next]
+        add [x], #1, [x]
+        out [x]
+        out [y]
+        jz #0, #wait
+addr:   data 0
+x:      data 0
+y:      data 0
+next:   data 0
+t:      data 0
+`, ringSize))
+
+// TestPacketNetworkRing runs the ring program on a packet network with a NAT, which stops it once
+// the packet has gone round twice
+func TestPacketNetworkRing(t *testing.T) {
+	for _, s := range schedulers {
+		t.Run(s.name, func(t *testing.T) {
+			program := Compile(ring)
+			network := NewPacketNetwork()
+			for i := 0; i < ringSize; i++ {
+				tape := program.NewTape()
+				network.Add(&tape)
+			}
+			nat := &NAT{}
+			network.SetMonitor(nat)
+			if err := network.Run(s.scheduler); err != nil {
+				t.Fatal(err)
+			}
+
+			var received []int
+			for _, p := range nat.Received {
+				received = append(received, p.X)
+			}
+			if expected := []int{ringSize - 1, 2*ringSize - 1}; !reflect.DeepEqual(received, expected) {
+				t.Errorf("the NAT received X values %v, expected %v", received, expected)
+			}
+			if y, ok := nat.Repeated(); !ok || y != 42 {
+				t.Errorf("the NAT repeated %d (%v), expected 42", y, ok)
+			}
+		})
+	}
+}
+
+// immediateDestination adds into an immediate operand, which has nowhere to store the result
+var immediateDestination = []int{11101, 1, 2, 5, 99}
+
+// TestImmediateDestination checks that storing to an immediate operand fails in every mode
+func TestImmediateDestination(t *testing.T) {
+	for _, m := range benchModes {
+		t.Run(m.name, func(t *testing.T) {
+			tape := m.newTape(immediateDestination)()
+			if err := tape.RunUntilHalt(); !errors.Is(err, ErrInvalidMode) {
+				t.Errorf("got %v, expected %v", err, ErrInvalidMode)
+			}
+		})
+	}
+}
+
+// namer asks for names, echoing each one with 1000 plus its length, until it is given an empty line
+var namer = mustParse("109,54,1206,0,12,204,0,109,1,1106,0,2,1101,0,1000,53,3,51,1008,51,10,52,1005,52,34,4,51,1001,53,1,53,1106,0,16,104,10,4,53,1008,53,1000,52,1005,52,50,109,-6,1106,0,2,99,0,0,0,78,97,109,101,63,32,0")
+
+// namerSession is a transcript of a session with namer
+const namerSession = `intcode-transcript 1
+out "Name? "
+in "ab"
+out "ab\n"
+value 1002
+out "Name? "
+in "cde"
+out "cde\n"
+value 1003
+out "Name? "
+in ""
+out "\n"
+value 1000
+`
+
+var replayCases = []struct {
+	name    string
+	session string
+	// mismatch is the index of the entry which doesn't match, or -1 if the whole session matches
+	mismatch int
+}{
+	{"match", namerSession, -1},
+	{"mismatch", strings.Replace(namerSession, "value 1003", "value 1004", 1), 7},
+}
+
+// TestTranscriptReplay replays transcripts against namer
+func TestTranscriptReplay(t *testing.T) {
+	for _, c := range replayCases {
+		t.Run(c.name, func(t *testing.T) {
+			transcript, err := LoadTranscript(strings.NewReader(c.session))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tape := CreateTapeCopy(namer)
+			mismatch := -1
+			var mismatchErr *TranscriptMismatch
+			if err := transcript.Replay(&tape); errors.As(err, &mismatchErr) {
+				mismatch = mismatchErr.Entry
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if mismatch != c.mismatch {
+				t.Errorf("got mismatch at %d, expected %d", mismatch, c.mismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,422 @@
+package intcode
+
+import "sync/atomic"
+
+// maxBlockLength is the most instructions compiled into a single block
+const maxBlockLength = 256
+
+// compiledOp runs one compiled instruction. It returns false without changing anything if the
+// instruction would fail, leaving the interpreter to run it and report the error.
+type compiledOp func(t *Tape) bool
+
+// block is a straight run of instructions compiled to Go closures. It ends at the first jump or
+// output (which is included), or just before the first instruction which is left to the
+// interpreter: halt, and anything which would fail whatever the state of the tape.
+type block struct {
+	start, end int
+	ops        []compiledOp
+	// masks has a bit set for each cell of the block, in the words of a compiledTape's written
+	// bits from the one holding start
+	masks []uint64
+}
+
+// noBlock marks an address which has no usable block, so it isn't looked up again
+var noBlock = &block{}
+
+// Program is a tape image compiled to Go closures a basic block at a time, as each block is
+// first run. Tapes created from the same program share its compiled blocks and its memory
+// (until they write to it), so it is cheap to run many copies of a program at once, including
+// from different goroutines.
+type Program struct {
+	data  []int
+	image *memory
+	// blocks holds the block starting at each address, once it has been compiled. Slots are
+	// filled in atomically, so tapes on different goroutines can look blocks up without locking.
+	blocks []atomic.Pointer[block]
+}
+
+// Compile prepares data to be run as compiled code by tapes created with NewTape
+func Compile(data []int) *Program {
+	image := newMemory(data)
+	image.token = frozen
+	return &Program{
+		data:   append([]int(nil), data...),
+		image:  image,
+		blocks: make([]atomic.Pointer[block], len(data)),
+	}
+}
+
+// NewTape creates a tape which runs the program's compiled code. Compiled tapes behave exactly
+// like interpreted ones. Blocks which the tape overwrites go back to being interpreted, as does
+// everything while an observer is attached.
+func (p *Program) NewTape() Tape {
+	return Tape{data: p.image.clone(), compiled: &compiledTape{program: p}}
+}
+
+// block returns the block starting at address, compiling it if this is the first time it is
+// needed. If two tapes compile the same block at once, both use whichever is stored first.
+func (p *Program) block(address int) *block {
+	slot := &p.blocks[address]
+	if b := slot.Load(); b != nil {
+		return b
+	}
+	b := p.compileBlock(address)
+	if !slot.CompareAndSwap(nil, b) {
+		b = slot.Load()
+	}
+	return b
+}
+
+func (p *Program) compileBlock(start int) *block {
+	b := &block{start: start, end: start}
+	for len(b.ops) < maxBlockLength && b.end < len(p.data) {
+		op, length, last := compileInstruction(p.data, b.end)
+		if op == nil {
+			break
+		}
+		b.ops = append(b.ops, op)
+		b.end += length
+		if last {
+			break
+		}
+	}
+
+	if len(b.ops) == 0 {
+		return noBlock
+	}
+	for word := b.start / 64; word <= (b.end-1)/64; word++ {
+		mask := ^uint64(0)
+		if word == b.start/64 {
+			mask &= ^uint64(0) << (b.start % 64)
+		}
+		if word == (b.end-1)/64 {
+			mask &= ^uint64(0) >> (63 - (b.end-1)%64)
+		}
+		b.masks = append(b.masks, mask)
+	}
+	return b
+}
+
+// compileParam checks that a param can be compiled: the mode is valid, and a position mode
+// address is not negative. Immediate destinations are invalid, so they are left to the interpreter
+// to report.
+func compileParam(value int, mode int, destination bool) (param, bool) {
+	switch mode {
+	case positionMode:
+		return param{value, mode}, value >= 0
+	case immediateMode:
+		return param{value, mode}, !destination
+	case relativeMode:
+		return param{value, mode}, true
+	default:
+		return param{}, false
+	}
+}
+
+// load returns the value of a compiled param. ok is false if the address it refers to is invalid.
+func (t *Tape) load(p param) (int, bool) {
+	switch p.mode {
+	case immediateMode:
+		return p.value, true
+	case positionMode:
+		return t.data.read(p.value), true
+	}
+	address := t.relativeBase + p.value
+	if address < 0 {
+		return 0, false
+	}
+	return t.data.read(address), true
+}
+
+// loadFixed returns the value of a compiled position or immediate mode param, which is always valid
+func (t *Tape) loadFixed(p param) int {
+	if p.mode == immediateMode {
+		return p.value
+	}
+	return t.data.read(p.value)
+}
+
+// address returns the address a compiled destination param refers to
+func (t *Tape) address(p param) (int, bool) {
+	if p.mode == positionMode {
+		return p.value, true
+	}
+	address := t.relativeBase + p.value
+	return address, address >= 0
+}
+
+// compileInstruction compiles the instruction at address in data, and returns its length and
+// whether it must be the last in its block: a jump, or an output, so that callers waiting for
+// output see it straight away. op is nil if the instruction is left to the interpreter.
+func compileInstruction(data []int, address int) (op compiledOp, length int, last bool) {
+	d, err := decodeValue(data[address])
+	if err != nil {
+		return nil, 0, false
+	}
+	opcode, paramCount := int(d.opcode), int(d.paramCount)
+	length = paramCount + 1
+	if address+length > len(data) {
+		return nil, 0, false
+	}
+
+	var params [3]param
+	for i := 0; i < paramCount; i++ {
+		p, ok := compileParam(data[address+1+i], int(d.modes[i]), i == int(d.destination))
+		if !ok {
+			return nil, 0, false
+		}
+		params[i] = p
+	}
+
+	next := address + length
+	switch opcode {
+	case addOpcode, multiplyOpcode, lessThanOpcode, equalsOpcode:
+		return compileArithmetic(opcode, params, next), length, false
+	case relativeAdjustOpcode:
+		adjust := params[0]
+		return func(t *Tape) bool {
+			x, ok := t.load(adjust)
+			if !ok {
+				return false
+			}
+			t.relativeBase += x
+			t.cursor = next
+			return true
+		}, length, false
+	case inputOpcode:
+		// Only queued input is read here. Reading from a source may block or prompt, so that is
+		// left to the interpreter, as is waiting for input.
+		destination := params[0]
+		return func(t *Tape) bool {
+			address, ok := t.address(destination)
+			if !ok || t.input.Empty() {
+				return false
+			}
+			t.waiting = false
+			t.data.write(address, t.input.Pop())
+			t.modified(address)
+			t.cursor = next
+			return true
+		}, length, false
+	case outputOpcode:
+		// A connected output can fail once it has been written to, so that is left to the interpreter
+		value := params[0]
+		return func(t *Tape) bool {
+			if t.sink != nil {
+				return false
+			}
+			x, ok := t.load(value)
+			if !ok {
+				return false
+			}
+			t.writeOutput(x)
+			t.cursor = next
+			return true
+		}, length, true
+	case jumpIfTrueOpcode, jumpIfFalseOpcode:
+		test, target := params[0], params[1]
+		jumpIf := opcode == jumpIfTrueOpcode
+		if test.mode != relativeMode && target.mode != relativeMode {
+			return func(t *Tape) bool {
+				if (t.loadFixed(test) != 0) == jumpIf {
+					t.cursor = t.loadFixed(target)
+				} else {
+					t.cursor = next
+				}
+				return true
+			}, length, true
+		}
+		return func(t *Tape) bool {
+			x, ok := t.load(test)
+			if !ok {
+				return false
+			}
+			to, ok := t.load(target)
+			if !ok {
+				return false
+			}
+			if (x != 0) == jumpIf {
+				t.cursor = to
+			} else {
+				t.cursor = next
+			}
+			return true
+		}, length, true
+	default:
+		return nil, 0, false
+	}
+}
+
+// compileArithmetic compiles an instruction which combines two params and stores the result in the third
+func compileArithmetic(opcode int, params [3]param, next int) compiledOp {
+	left, right, destination := params[0], params[1], params[2]
+	if left.mode != relativeMode && right.mode != relativeMode && destination.mode == positionMode {
+		// Without relative params every address is known to be valid, so the instruction can't fail
+		address := destination.value
+		return func(t *Tape) bool {
+			t.data.write(address, wrapArithmetic(opcode, t.loadFixed(left), t.loadFixed(right)))
+			t.modified(address)
+			t.cursor = next
+			return true
+		}
+	}
+
+	return func(t *Tape) bool {
+		x, ok := t.load(left)
+		if !ok {
+			return false
+		}
+		y, ok := t.load(right)
+		if !ok {
+			return false
+		}
+		address, ok := t.address(destination)
+		if !ok {
+			return false
+		}
+		t.data.write(address, wrapArithmetic(opcode, x, y))
+		t.modified(address)
+		t.cursor = next
+		return true
+	}
+}
+
+// wrapArithmetic applies an add, multiply, less than or equals opcode to x and y, wrapping on
+// overflow as WrapWords does. Compiled code only runs in WrapWords mode, so unlike combine it
+// doesn't check for overflow.
+func wrapArithmetic(opcode int, x int, y int) int {
+	switch opcode {
+	case addOpcode:
+		return x + y
+	case multiplyOpcode:
+		return x * y
+	case lessThanOpcode:
+		if x < y {
+			return 1
+		}
+	case equalsOpcode:
+		if x == y {
+			return 1
+		}
+	}
+	return 0
+}
+
+// compiledTape is a tape's view of the program it was created from: which parts of the program
+// it has overwritten, and so can no longer run compiled
+type compiledTape struct {
+	program *Program
+	// written has a bit set for each cell of the program which no longer holds the value it was
+	// compiled from. It is nil until the tape first writes over part of the program.
+	written []uint64
+	// running is the block being run, and stale is set when a write changes it, so that it stops
+	running *block
+	stale   bool
+}
+
+// link creates the compiled view of the program for a tape with the given memory
+func (p *Program) link(data *memory) *compiledTape {
+	c := &compiledTape{program: p}
+	for i, x := range p.data {
+		if data.read(i) != x {
+			c.write(i)
+		}
+	}
+	return c
+}
+
+// program returns the program a tape was created from, or nil if it isn't compiled
+func (t *Tape) program() *Program {
+	if t.compiled == nil {
+		return nil
+	}
+	return t.compiled.program
+}
+
+// blockAt returns the block to run at address, or nil if the interpreter should run it
+func (c *compiledTape) blockAt(address int) *block {
+	if address < 0 || address >= len(c.program.data) {
+		return nil
+	}
+	b := c.program.block(address)
+	if b == noBlock || c.overwritten(b) {
+		return nil
+	}
+	return b
+}
+
+// overwritten returns whether the tape has written to any of the cells of a block
+func (c *compiledTape) overwritten(b *block) bool {
+	if c.written == nil {
+		return false
+	}
+	written := c.written[b.start/64:]
+	for i, mask := range b.masks {
+		if written[i]&mask != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// write marks a cell of the program as written
+func (c *compiledTape) write(address int) {
+	if c.written == nil {
+		c.written = make([]uint64, (len(c.program.data)+63)/64)
+	}
+	c.written[address/64] |= 1 << (address % 64)
+}
+
+// modified records a write to address, which stops the running block if it is part of it
+func (c *compiledTape) modified(address int) {
+	if address >= len(c.program.data) {
+		return
+	}
+	if r := c.running; r != nil && r.start <= address && address < r.end {
+		c.stale = true
+	}
+	c.write(address)
+}
+
+// runBlock runs compiled blocks from the cursor, one after another, until it reaches code the
+// interpreter must run or an output, and returns how many instructions were run. At most limit
+// instructions are run, unless limit is 0.
+func (t *Tape) runBlock(limit int) int {
+	if t.compiled == nil || t.words != WrapWords || t.recording() {
+		return 0
+	}
+
+	c := t.compiled
+	outputCount := t.outputCount
+	total := 0
+	for limit == 0 || total < limit {
+		b := c.blockAt(t.cursor)
+		if b == nil {
+			break
+		}
+		ops := b.ops
+		if limit > 0 && len(ops) > limit-total {
+			ops = ops[:limit-total]
+		}
+
+		c.running = b
+		n := 0
+		for _, op := range ops {
+			if !op(t) {
+				break
+			}
+			n++
+			if c.stale {
+				c.stale = false
+				break
+			}
+		}
+		c.running = nil
+		t.instructions += n
+		total += n
+		if n < len(b.ops) || t.outputCount != outputCount {
+			break
+		}
+	}
+	return total
+}
@@ -0,0 +1,232 @@
+package intcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const coverageHeader = "intcode-coverage 1"
+
+// BranchCoverage counts which way a conditional jump went
+type BranchCoverage struct {
+	Taken    int
+	NotTaken int
+}
+
+// Coverage is an Observer which records which instructions run, and which ways each conditional
+// jump goes. Coverage from many runs of the same program can be merged, to see what a set of
+// inputs exercises between them.
+type Coverage struct {
+	executed map[int]int
+	branches map[int]*BranchCoverage
+}
+
+// CoverageSummary totals up a coverage report against a disassembly of the program
+type CoverageSummary struct {
+	Instructions, CoveredInstructions int
+	// Branches counts both directions of every conditional jump, as each can be covered separately.
+	// Jumps with an immediate test value can only go one way, so they aren't counted.
+	Branches, CoveredBranches int
+}
+
+// NewCoverage creates an empty coverage record. Attach it to a tape to record coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{executed: map[int]int{}, branches: map[int]*BranchCoverage{}}
+}
+
+func (c *Coverage) branch(address int) *BranchCoverage {
+	b, ok := c.branches[address]
+	if !ok {
+		b = &BranchCoverage{}
+		c.branches[address] = b
+	}
+	return b
+}
+
+// conditional returns whether an instruction is a jump which can go either way, rather than
+// one with an immediate test value, which assemblers use for unconditional jumps
+func conditional(in Instruction) bool {
+	return (in.Opcode == jumpIfTrueOpcode || in.Opcode == jumpIfFalseOpcode) && in.Operands[0].Mode != immediateMode
+}
+
+// Observe records a step
+func (c *Coverage) Observe(t *Tape, step *Step) {
+	c.executed[step.Cursor]++
+
+	if step.Opcode == jumpIfTrueOpcode || step.Opcode == jumpIfFalseOpcode {
+		b := c.branch(step.Cursor)
+		if (step.Operands[0] != 0) == (step.Opcode == jumpIfTrueOpcode) {
+			b.Taken++
+		} else {
+			b.NotTaken++
+		}
+	}
+}
+
+// ObserveHalt counts the halt instruction a run stopped on, as halts never run as a step
+func (c *Coverage) ObserveHalt(t *Tape, cursor int) {
+	c.executed[cursor]++
+}
+
+// Merge adds the coverage recorded by other to c
+func (c *Coverage) Merge(other *Coverage) {
+	for address, count := range other.executed {
+		c.executed[address] += count
+	}
+	for address, ob := range other.branches {
+		b := c.branch(address)
+		b.Taken += ob.Taken
+		b.NotTaken += ob.NotTaken
+	}
+}
+
+// Executed returns how many times the instruction at address ran
+func (c *Coverage) Executed(address int) int {
+	return c.executed[address]
+}
+
+// Branch returns how many times the conditional jump at address was and wasn't taken
+func (c *Coverage) Branch(address int) BranchCoverage {
+	if b, ok := c.branches[address]; ok {
+		return *b
+	}
+	return BranchCoverage{}
+}
+
+// addresses returns every address which has run, in order
+func (c *Coverage) addresses() []int {
+	addresses := make([]int, 0, len(c.executed))
+	for address := range c.executed {
+		addresses = append(addresses, address)
+	}
+	sort.Ints(addresses)
+	return addresses
+}
+
+// Listing disassembles data, using every address which has run as an entry point, so that code
+// only reached through computed jumps is disassembled too
+func (c *Coverage) Listing(data []int) []Instruction {
+	return Disassemble(data, c.addresses()...)
+}
+
+// Summary totals up the coverage of the instructions in a listing
+func (c *Coverage) Summary(listing []Instruction) CoverageSummary {
+	var s CoverageSummary
+	for _, in := range listing {
+		if in.IsData() {
+			continue
+		}
+		s.Instructions++
+		if c.executed[in.Address] > 0 {
+			s.CoveredInstructions++
+		}
+		if conditional(in) {
+			b := c.Branch(in.Address)
+			s.Branches += 2
+			if b.Taken > 0 {
+				s.CoveredBranches++
+			}
+			if b.NotTaken > 0 {
+				s.CoveredBranches++
+			}
+		}
+	}
+	return s
+}
+
+func (s CoverageSummary) String() string {
+	return fmt.Sprintf("%d/%d instructions (%.1f%%), %d/%d branches (%.1f%%)",
+		s.CoveredInstructions, s.Instructions, percent(s.CoveredInstructions, s.Instructions),
+		s.CoveredBranches, s.Branches, percent(s.CoveredBranches, s.Branches))
+}
+
+// WriteAnnotatedListing writes a disassembly of data with each instruction prefixed by how many
+// times it ran, or "#####" if it never did, and each conditional jump followed by how often it was
+// taken. Data lines are prefixed with "-". Conditional jumps which only ever went one way are flagged.
+func (c *Coverage) WriteAnnotatedListing(w io.Writer, data []int) error {
+	listing := c.Listing(data)
+	if _, err := fmt.Fprintf(w, "; coverage: %s\n", c.Summary(listing)); err != nil {
+		return err
+	}
+
+	for _, in := range listing {
+		count := "-"
+		var note string
+		if !in.IsData() {
+			if n := c.executed[in.Address]; n > 0 {
+				count = fmt.Sprint(n)
+			} else {
+				count = "#####"
+			}
+		}
+		if conditional(in) {
+			b := c.Branch(in.Address)
+			note = fmt.Sprintf("  ; taken %d, not taken %d", b.Taken, b.NotTaken)
+			switch {
+			case b.Taken == 0 && b.NotTaken > 0:
+				note += " (never taken)"
+			case b.NotTaken == 0 && b.Taken > 0:
+				note += " (always taken)"
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%9s: %s%s\n", count, FormatLine(in), note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save writes the coverage to w, so it can be merged with coverage from later runs. The format is
+// line-based text: a header, then "exec <address> <count>" for each instruction which ran and
+// "branch <address> <taken> <not taken>" for each conditional jump.
+func (c *Coverage) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, coverageHeader)
+	for _, address := range c.addresses() {
+		fmt.Fprintf(bw, "exec %d %d\n", address, c.executed[address])
+		if b, ok := c.branches[address]; ok {
+			fmt.Fprintf(bw, "branch %d %d %d\n", address, b.Taken, b.NotTaken)
+		}
+	}
+	return bw.Flush()
+}
+
+// LoadCoverage reads coverage written by Save
+func LoadCoverage(r io.Reader) (*Coverage, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || scanner.Text() != coverageHeader {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("intcode: not a coverage file")
+	}
+
+	c := NewCoverage()
+	for line := 2; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		var address, taken, notTaken, count int
+		var err error
+		switch {
+		case len(fields) == 3 && fields[0] == "exec":
+			_, err = fmt.Sscan(strings.Join(fields[1:], " "), &address, &count)
+			c.executed[address] += count
+		case len(fields) == 4 && fields[0] == "branch":
+			_, err = fmt.Sscan(strings.Join(fields[1:], " "), &address, &taken, &notTaken)
+			b := c.branch(address)
+			b.Taken += taken
+			b.NotTaken += notTaken
+		default:
+			err = fmt.Errorf("unexpected %q", scanner.Text())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("intcode: coverage line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,90 @@
+package intcode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func runCoverage(t *testing.T, program []int, input ...int) *Coverage {
+	t.Helper()
+	coverage := NewCoverage()
+	tape := CreateTapeCopy(program)
+	tape.Attach(coverage)
+	for _, x := range input {
+		tape.Input(x)
+	}
+	if err := tape.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	return coverage
+}
+
+// TestCoverageHalt checks that the halt a run stops on is counted, even if nothing else runs
+func TestCoverageHalt(t *testing.T) {
+	program := []int{99}
+	coverage := runCoverage(t, program)
+	if n := coverage.Executed(0); n != 1 {
+		t.Errorf("halt ran %d times, expected 1", n)
+	}
+	summary := coverage.Summary(coverage.Listing(program))
+	if summary.Instructions != 1 || summary.CoveredInstructions != 1 {
+		t.Errorf("got %v, expected the halt covered", summary)
+	}
+
+	coverage = runCoverage(t, largeCompare, 8)
+	if n := coverage.Executed(46); n != 1 {
+		t.Errorf("final halt ran %d times, expected 1", n)
+	}
+}
+
+// TestCoverageMerge runs largeCompare either side of 8, so that the jump at 13 goes each way once
+func TestCoverageMerge(t *testing.T) {
+	below := runCoverage(t, largeCompare, 7)
+	above := runCoverage(t, largeCompare, 9)
+	merged := NewCoverage()
+	merged.Merge(below)
+	merged.Merge(above)
+
+	for _, address := range merged.addresses() {
+		if n := merged.Executed(address); n != below.Executed(address)+above.Executed(address) {
+			t.Errorf("merged coverage ran %d %d times, expected %d", address, n, below.Executed(address)+above.Executed(address))
+		}
+	}
+	if b := below.Branch(13); b != (BranchCoverage{Taken: 1}) {
+		t.Errorf("got %+v below 8, expected only taken", b)
+	}
+	if b := merged.Branch(13); b != (BranchCoverage{Taken: 1, NotTaken: 1}) {
+		t.Errorf("got %+v merged, expected taken and not taken once each", b)
+	}
+
+	listing := merged.Listing(largeCompare)
+	if s, b := merged.Summary(listing), below.Summary(listing); s.CoveredBranches <= b.CoveredBranches {
+		t.Errorf("merged coverage %v doesn't cover more branches than %v", s, b)
+	}
+}
+
+// TestCoverageSave checks that saved coverage loads back the same, and rejects what isn't coverage
+func TestCoverageSave(t *testing.T) {
+	coverage := runCoverage(t, largeCompare, 7)
+	var saved bytes.Buffer
+	if err := coverage.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCoverage(bytes.NewReader(saved.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resaved bytes.Buffer
+	if err := loaded.Save(&resaved); err != nil {
+		t.Fatal(err)
+	}
+	if resaved.String() != saved.String() {
+		t.Errorf("loaded coverage saves as\n%s\nexpected\n%s", resaved.String(), saved.String())
+	}
+
+	for _, corrupt := range []string{"", "exec 0 1\n", coverageHeader + "\nexec 0\n", coverageHeader + "\nbranch 0 x 1\n"} {
+		if _, err := LoadCoverage(bytes.NewReader([]byte(corrupt))); err == nil {
+			t.Errorf("loaded %q without error", corrupt)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package intcode
+
+import "fmt"
+
+// StopReason explains why the debugger stopped running a tape
+type StopReason int
+
+const (
+	// StoppedStep means the requested step finished
+	StoppedStep StopReason = iota
+	StoppedBreakpoint
+	StoppedWatchpoint
+	StoppedHalt
+	// StoppedFault means an instruction failed, and Stop.Err holds the error
+	StoppedFault
+	// StoppedHistoryStart means there were no more instructions in the history to step back through
+	StoppedHistoryStart
+)
+
+// DefaultHistoryLimit is how many instructions a debugger lets you step back through, unless
+// the tape's history limit is changed
+const DefaultHistoryLimit = 100000
+
+// Breakpoint stops the debugger before an instruction at an address, or with an opcode, runs
+type Breakpoint struct {
+	ID       int
+	Address  int
+	Opcode   int
+	OnOpcode bool
+}
+
+func (b Breakpoint) String() string {
+	if b.OnOpcode {
+		return fmt.Sprintf("breakpoint %d on %s", b.ID, opcodes[b.Opcode].mnemonic)
+	}
+	return fmt.Sprintf("breakpoint %d at %d", b.ID, b.Address)
+}
+
+// Watchpoint stops the debugger after an instruction reads or writes a memory cell
+type Watchpoint struct {
+	ID      int
+	Address int
+	Read    bool
+	Write   bool
+}
+
+func (w Watchpoint) String() string {
+	kind := "read/write"
+	if !w.Read {
+		kind = "write"
+	} else if !w.Write {
+		kind = "read"
+	}
+	return fmt.Sprintf("watchpoint %d on %s of %d", w.ID, kind, w.Address)
+}
+
+// Stop describes why the debugger stopped
+type Stop struct {
+	Reason     StopReason
+	Breakpoint Breakpoint
+	Watchpoint Watchpoint
+	// Access is the read or write which triggered a watchpoint
+	Access Access
+	Write  bool
+	Err    error
+}
+
+func (s Stop) String() string {
+	switch s.Reason {
+	case StoppedBreakpoint:
+		return "hit " + s.Breakpoint.String()
+	case StoppedWatchpoint:
+		if s.Write {
+			return fmt.Sprintf("hit %s: %d -> %d", s.Watchpoint, s.Access.Previous, s.Access.Value)
+		}
+		return fmt.Sprintf("hit %s: read %d", s.Watchpoint, s.Access.Value)
+	case StoppedHalt:
+		return "halted"
+	case StoppedFault:
+		return s.Err.Error()
+	case StoppedHistoryStart:
+		return "reached the start of the history"
+	default:
+		return "stepped"
+	}
+}
+
+// Debugger runs a tape under the control of breakpoints and watchpoints
+type Debugger struct {
+	tape        *Tape
+	breakpoints []Breakpoint
+	watchpoints []Watchpoint
+	nextID      int
+	// hit is set while observing an instruction which triggered a watchpoint
+	hit *Stop
+}
+
+// NewDebugger attaches a debugger to a tape. If the tape isn't already keeping a history,
+// one of DefaultHistoryLimit instructions is started, so that the debugger can step back.
+func NewDebugger(t *Tape) *Debugger {
+	d := &Debugger{tape: t, nextID: 1}
+	t.Attach(d)
+	if t.HistoryLimit() == 0 {
+		t.SetHistoryLimit(DefaultHistoryLimit)
+	}
+	return d
+}
+
+// Tape returns the tape being debugged
+func (d *Debugger) Tape() *Tape {
+	return d.tape
+}
+
+// Observe checks each executed instruction against the watchpoints
+func (d *Debugger) Observe(t *Tape, step *Step) {
+	d.hit = d.watched(step)
+}
+
+// watched returns the stop for the first watchpoint triggered by a step, or nil if none were
+func (d *Debugger) watched(step *Step) *Stop {
+	for _, w := range d.watchpoints {
+		if w.Write {
+			for _, access := range step.Writes {
+				if access.Address == w.Address {
+					return &Stop{Reason: StoppedWatchpoint, Watchpoint: w, Access: access, Write: true}
+				}
+			}
+		}
+		if w.Read {
+			for _, access := range step.Reads {
+				if access.Address == w.Address {
+					return &Stop{Reason: StoppedWatchpoint, Watchpoint: w, Access: access}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Debugger) newID() int {
+	id := d.nextID
+	d.nextID++
+	return id
+}
+
+// AddBreakpoint stops the debugger before the instruction at address runs
+func (d *Debugger) AddBreakpoint(address int) Breakpoint {
+	b := Breakpoint{ID: d.newID(), Address: address}
+	d.breakpoints = append(d.breakpoints, b)
+	return b
+}
+
+// AddOpcodeBreakpoint stops the debugger before any instruction with the given opcode runs
+func (d *Debugger) AddOpcodeBreakpoint(opcode int) (Breakpoint, error) {
+	if _, ok := opcodes[opcode]; !ok {
+		return Breakpoint{}, fmt.Errorf("%w: %d", ErrInvalidOpcode, opcode)
+	}
+	b := Breakpoint{ID: d.newID(), Opcode: opcode, OnOpcode: true}
+	d.breakpoints = append(d.breakpoints, b)
+	return b, nil
+}
+
+// AddWatchpoint stops the debugger after an instruction reads and/or writes address
+func (d *Debugger) AddWatchpoint(address int, read bool, write bool) Watchpoint {
+	w := Watchpoint{ID: d.newID(), Address: address, Read: read, Write: write}
+	d.watchpoints = append(d.watchpoints, w)
+	return w
+}
+
+// Delete removes the breakpoint or watchpoint with the given id, and returns whether it existed
+func (d *Debugger) Delete(id int) bool {
+	for i, b := range d.breakpoints {
+		if b.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	for i, w := range d.watchpoints {
+		if w.ID == id {
+			d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Breakpoints returns the current breakpoints
+func (d *Debugger) Breakpoints() []Breakpoint {
+	return append([]Breakpoint(nil), d.breakpoints...)
+}
+
+// Watchpoints returns the current watchpoints
+func (d *Debugger) Watchpoints() []Watchpoint {
+	return append([]Watchpoint(nil), d.watchpoints...)
+}
+
+// breakpointAt returns the breakpoint, if any, for the instruction at the cursor
+func (d *Debugger) breakpointAt() (Breakpoint, bool) {
+	value := d.tape.Value()
+	opcode, _ := decodeOpcode(value)
+	for _, b := range d.breakpoints {
+		if (b.OnOpcode && b.Opcode == opcode) || (!b.OnOpcode && b.Address == d.tape.cursor) {
+			return b, true
+		}
+	}
+	return Breakpoint{}, false
+}
+
+// run runs instructions until done returns true or something stops the tape. A breakpoint
+// on the first instruction is ignored, so that continuing from a breakpoint makes progress.
+func (d *Debugger) run(done func() bool) Stop {
+	for first := true; ; first = false {
+		if !first {
+			if b, ok := d.breakpointAt(); ok {
+				return Stop{Reason: StoppedBreakpoint, Breakpoint: b}
+			}
+		}
+		if d.tape.IsHalted() {
+			return Stop{Reason: StoppedHalt}
+		}
+
+		d.hit = nil
+		if err := d.tape.RunNextInstruction(); err != nil {
+			return Stop{Reason: StoppedFault, Err: err}
+		}
+		if d.hit != nil {
+			return *d.hit
+		}
+		if done() {
+			return Stop{Reason: StoppedStep}
+		}
+	}
+}
+
+// Step runs a single instruction
+func (d *Debugger) Step() Stop {
+	return d.run(func() bool { return true })
+}
+
+// StepOver runs until the cursor reaches the instruction after the current one. For the
+// unconditional jumps used to call subroutines, this runs the whole call.
+func (d *Debugger) StepOver() Stop {
+	next := d.tape.cursor + d.tape.instructionAt(d.tape.cursor).Len()
+	return d.run(func() bool { return d.tape.cursor == next })
+}
+
+// Continue runs until a breakpoint or watchpoint is hit, or the tape halts or fails
+func (d *Debugger) Continue() Stop {
+	return d.run(func() bool { return false })
+}
+
+// reverse steps back through the tape's history until done returns true, an instruction which
+// triggers a watchpoint is undone, the cursor reaches a breakpoint, or the history runs out.
+// The undone instruction is left at the cursor, so stepping forward runs it again.
+func (d *Debugger) reverse(done func() bool) Stop {
+	for {
+		step, err := d.tape.StepBack()
+		if err != nil {
+			return Stop{Reason: StoppedHistoryStart}
+		}
+		if hit := d.watched(&step); hit != nil {
+			return *hit
+		}
+		if b, ok := d.breakpointAt(); ok {
+			return Stop{Reason: StoppedBreakpoint, Breakpoint: b}
+		}
+		if done() {
+			return Stop{Reason: StoppedStep}
+		}
+	}
+}
+
+// StepBack undoes the last instruction
+func (d *Debugger) StepBack() Stop {
+	return d.reverse(func() bool { return true })
+}
+
+// ReverseContinue steps back until the last instruction which triggered a watchpoint, such as
+// the previous write of a watched address, or the last time a breakpoint was reached
+func (d *Debugger) ReverseContinue() Stop {
+	return d.reverse(func() bool { return false })
+}
+
+// List decodes count instructions from the tape's current memory, starting at address
+func (d *Debugger) List(address int, count int) []Instruction {
+	var listing []Instruction
+	for i := 0; i < count && address >= 0; i++ {
+		in := d.tape.instructionAt(address)
+		listing = append(listing, in)
+		address += in.Len()
+	}
+	return listing
+}
@@ -0,0 +1,70 @@
+package intcode
+
+import "testing"
+
+func newCountdownDebugger(n int) *Debugger {
+	tape := CreateTapeCopy(countdown)
+	tape.Input(n)
+	return NewDebugger(&tape)
+}
+
+// TestDebuggerBreakpoints stops at the loop's jump on every pass, and then at the output
+func TestDebuggerBreakpoints(t *testing.T) {
+	d := newCountdownDebugger(3)
+	loop := d.AddBreakpoint(10)
+	output, err := d.AddOpcodeBreakpoint(outputOpcode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 2; n >= 0; n-- {
+		stop := d.Continue()
+		if stop.Reason != StoppedBreakpoint || stop.Breakpoint != loop || d.Tape().Cursor() != 10 {
+			t.Fatalf("got %v at %d, expected %v at 10", stop, d.Tape().Cursor(), loop)
+		}
+		if value, _ := d.Tape().Peek(16); value != n {
+			t.Errorf("counter is %d at the breakpoint, expected %d", value, n)
+		}
+	}
+	if stop := d.Continue(); stop.Reason != StoppedBreakpoint || stop.Breakpoint != output || d.Tape().Cursor() != 13 {
+		t.Fatalf("got %v at %d, expected %v at 13", stop, d.Tape().Cursor(), output)
+	}
+
+	if !d.Delete(output.ID) || d.Delete(output.ID) {
+		t.Errorf("deleting breakpoint %d didn't remove it exactly once", output.ID)
+	}
+	if stop := d.Continue(); stop.Reason != StoppedHalt {
+		t.Fatalf("got %v, expected the tape to halt", stop)
+	}
+	if output := d.Tape().PendingOutput(); len(output) != 1 || output[0] != 6 {
+		t.Errorf("got output %v, expected [6]", output)
+	}
+
+	if _, err := d.AddOpcodeBreakpoint(42); err == nil {
+		t.Errorf("added a breakpoint on an invalid opcode")
+	}
+}
+
+// TestDebuggerWatchpoints stops on each write of the running total, and on reads of the counter
+func TestDebuggerWatchpoints(t *testing.T) {
+	d := newCountdownDebugger(3)
+	sum := d.AddWatchpoint(17, false, true)
+
+	for _, expected := range []Access{{17, 3, 0}, {17, 5, 3}, {17, 6, 5}} {
+		stop := d.Continue()
+		if stop.Reason != StoppedWatchpoint || stop.Watchpoint != sum || !stop.Write || stop.Access != expected {
+			t.Fatalf("got %v, expected a write of %+v", stop, expected)
+		}
+		// The watchpoint stops after the write, so the cursor has moved on
+		if d.Tape().Cursor() != 6 {
+			t.Errorf("stopped at %d, expected 6", d.Tape().Cursor())
+		}
+	}
+	d.Delete(sum.ID)
+
+	counter := d.AddWatchpoint(16, true, false)
+	stop := d.Continue()
+	if stop.Reason != StoppedWatchpoint || stop.Watchpoint != counter || stop.Write || stop.Access != (Access{Address: 16, Value: 1}) {
+		t.Fatalf("got %v, expected a read of the counter", stop)
+	}
+}
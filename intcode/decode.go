@@ -0,0 +1,125 @@
+package intcode
+
+import "fmt"
+
+// decodeCacheLimit is the highest address whose decoding is cached. Code is almost always near the
+// start of the tape, so this keeps a program which jumps far away from growing the cache without limit.
+const decodeCacheLimit = 1 << 16
+
+// minDecodeCache is the smallest cache to allocate, so that small programs only allocate it once
+const minDecodeCache = 64
+
+// decodeCacheAfter is how many instructions a tape runs before it starts caching decoded
+// instructions. Most short runs, like each of day 2's or day 7's, never loop enough to win back
+// the cost of allocating the cache.
+const decodeCacheAfter = 256
+
+// decoded is an instruction value split into its opcode and param modes. It is kept small, as the
+// cache holds one for every address.
+type decoded struct {
+	opcode      uint8
+	paramCount  uint8
+	destination int8
+	modes       [3]uint8
+	// valid is false for cache entries which have not been filled in, or have been written over
+	valid bool
+}
+
+// opcodeTable holds the same information as opcodes, indexed by opcode so it can be looked up
+// without hashing on every instruction
+var opcodeTable = func() (table [100]*opcodeInfo) {
+	for opcode, info := range opcodes {
+		info := info
+		table[opcode] = &info
+	}
+	return table
+}()
+
+// decodeOpcode returns the opcode part of an instruction value. Unknown opcodes are returned as is,
+// but negative values can't be instructions at all.
+func decodeOpcode(value int) (int, error) {
+	if value < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidOpcode, value)
+	}
+	return value % 100, nil
+}
+
+// decodeMode returns the mode of the param at index i of an instruction
+func decodeMode(value int, i int) int {
+	value /= 100
+	for ; i > 0; i-- {
+		value /= 10
+	}
+	return value % 10
+}
+
+// decodeValue decodes an instruction value. Modes are not checked here, as an invalid mode is only
+// an error if the param is used.
+func decodeValue(value int) (decoded, error) {
+	opcode, err := decodeOpcode(value)
+	if err != nil {
+		return decoded{}, err
+	}
+	info := opcodeTable[opcode]
+	if info == nil {
+		return decoded{}, fmt.Errorf("%w: %d", ErrInvalidOpcode, opcode)
+	}
+
+	d := decoded{opcode: uint8(opcode), paramCount: uint8(info.paramCount), destination: int8(info.destination), valid: true}
+	modes := value / 100
+	for i := 0; i < info.paramCount; i++ {
+		d.modes[i] = uint8(modes % 10)
+		modes /= 10
+	}
+	return d, nil
+}
+
+// decode returns the decoding of value, the instruction at address, from the cache if it is there
+func (t *Tape) decode(address int, value int) (decoded, error) {
+	if address < len(t.cache) && t.cache[address].valid {
+		return t.cache[address], nil
+	}
+
+	d, err := decodeValue(value)
+	if err != nil || t.uncached || address >= decodeCacheLimit || (t.cache == nil && t.instructions < decodeCacheAfter) {
+		return d, err
+	}
+
+	if address >= len(t.cache) {
+		size := 2 * len(t.cache)
+		if size <= address {
+			size = address + 1
+		}
+		if size < minDecodeCache {
+			size = minDecodeCache
+		}
+		if size > decodeCacheLimit {
+			size = decodeCacheLimit
+		}
+		cache := make([]decoded, size)
+		copy(cache, t.cache)
+		t.cache = cache
+	}
+	t.cache[address] = d
+	return d, nil
+}
+
+// modified forgets anything decoded or compiled from the value at address, after something writes there
+func (t *Tape) modified(address int) {
+	if address < len(t.cache) {
+		t.cache[address].valid = false
+	}
+	if t.compiled != nil {
+		t.compiled.modified(address)
+	}
+	if t.bigs != nil {
+		delete(t.bigs, address)
+	}
+}
+
+// SetDecodeCache turns the decoded instruction cache on or off. It is on by default; turning it
+// off is only useful for measuring how much it helps.
+func (t *Tape) SetDecodeCache(enabled bool) {
+	t.uncached = !enabled
+	t.cache = nil
+}
@@ -0,0 +1,440 @@
+package intcode
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// decompiledLine is a line of pseudo-code. Lines with a label are markers for the start of a
+// block, which are only written if something jumps there with a goto.
+type decompiledLine struct {
+	indent int
+	text   string
+	label  int
+}
+
+// loopContext describes the loop being decompiled, so jumps out of it and back to the top of it
+// can be written as break and continue
+type loopContext struct {
+	header, exit int
+	// backEdge is the block whose jump closes the loop
+	backEdge int
+}
+
+// decompiler lifts the blocks of one function into pseudo-code
+type decompiler struct {
+	function int
+	// frame is how far the function's prologue moves the relative base, or 0 if it has none
+	frame int
+	// params maps each function to the slots above the caller's relative base it is passed
+	// arguments in, as found by callParams
+	params map[int][]int
+	lines  []decompiledLine
+	gotos  map[int]bool
+}
+
+// decompiledName returns the name a function is called in pseudo-code
+func decompiledName(entry int) string {
+	if entry == 0 {
+		return "main"
+	}
+	return fmt.Sprintf("f%04d", entry)
+}
+
+// frameSize returns how far a function's prologue moves the relative base
+func frameSize(entry *BasicBlock) int {
+	first := entry.Instructions[0]
+	if first.Opcode == relativeAdjustOpcode && first.Operands[0].Mode == immediateMode && first.Operands[0].Value > 0 {
+		return first.Operands[0].Value
+	}
+	return 0
+}
+
+// WriteDecompiled writes a tape image as structured pseudo-code, one function at a time, using
+// the control-flow graph from BuildCFG. Jump patterns are lifted into if/else, loops, break and
+// continue where possible, and goto otherwise. In functions whose prologue moves the relative
+// base, the cells of the stack frame are named local1, local2 and so on, counting up from the
+// return address. Other relative cells are written rb[N], and absolute ones mem[N]. The arguments
+// stored above the relative base before a call are the function's parameters, named the way the
+// function sees those cells; a call which doesn't pass one of them gives _ for it.
+func WriteDecompiled(w io.Writer, data []int, entries ...int) error {
+	g := BuildCFG(data, entries...)
+
+	byFunction := map[int][]*BasicBlock{}
+	var functions []int
+	for _, b := range g.Blocks {
+		if _, ok := byFunction[b.Function]; !ok {
+			functions = append(functions, b.Function)
+		}
+		byFunction[b.Function] = append(byFunction[b.Function], b)
+	}
+	sort.Ints(functions)
+	params := callParams(g.Blocks)
+
+	for i, function := range functions {
+		d := &decompiler{function: function, params: params, gotos: map[int]bool{}}
+		entry := g.Block(function)
+		if function != 0 && entry != nil {
+			d.frame = frameSize(entry)
+		}
+		var names []string
+		for _, slot := range params[function] {
+			names = append(names, d.variable(slot-d.frame))
+		}
+		header := fmt.Sprintf("func %s(%s) {", decompiledName(function), strings.Join(names, ", "))
+		if d.frame > 0 {
+			header += fmt.Sprintf("  // frame %d", d.frame)
+		}
+		d.emit(byFunction[function], 1, nil, -1)
+
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w, header); err != nil {
+			return err
+		}
+		for _, line := range d.lines {
+			if line.label >= 0 {
+				if !d.gotos[line.label] {
+					continue
+				}
+				line.text = fmt.Sprintf("L%04d:", line.label)
+				line.indent--
+			}
+			if _, err := fmt.Fprintf(w, "%s%s\n", strings.Repeat("    ", line.indent), line.text); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w, "}"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decompiler) line(indent int, format string, args ...interface{}) {
+	d.lines = append(d.lines, decompiledLine{indent: indent, text: fmt.Sprintf(format, args...), label: -1})
+}
+
+func (d *decompiler) gotoLine(indent int, condition string, target int) {
+	d.gotos[target] = true
+	if condition == "" {
+		d.line(indent, "goto L%04d", target)
+	} else {
+		d.line(indent, "if (%s) goto L%04d", condition, target)
+	}
+}
+
+// variable names the cell a relative param refers to
+func (d *decompiler) variable(offset int) string {
+	if d.frame > 0 && offset < 0 && offset+d.frame > 0 {
+		return fmt.Sprintf("local%d", offset+d.frame)
+	}
+	return fmt.Sprintf("rb[%d]", offset)
+}
+
+func (d *decompiler) operand(o Operand) string {
+	switch o.Mode {
+	case immediateMode:
+		return strconv.Itoa(o.Value)
+	case positionMode:
+		return fmt.Sprintf("mem[%d]", o.Value)
+	default:
+		return d.variable(o.Value)
+	}
+}
+
+// value returns the expression for the value an instruction stores
+func (d *decompiler) value(in Instruction) string {
+	if in.Opcode == inputOpcode {
+		return "input()"
+	}
+
+	a, b := in.Operands[0], in.Operands[1]
+	isImmediate := func(o Operand, x int) bool {
+		return o.Mode == immediateMode && o.Value == x
+	}
+	switch {
+	case in.Opcode == addOpcode && isImmediate(a, 0):
+		return d.operand(b)
+	case in.Opcode == addOpcode && isImmediate(b, 0), in.Opcode == multiplyOpcode && isImmediate(b, 1):
+		return d.operand(a)
+	case in.Opcode == multiplyOpcode && isImmediate(a, 1):
+		return d.operand(b)
+	case in.Opcode == multiplyOpcode && (isImmediate(a, 0) || isImmediate(b, 0)):
+		return "0"
+	case a.Mode == immediateMode && b.Mode == immediateMode:
+		if x, ok := combine(in.Opcode, a.Value, b.Value); ok {
+			return strconv.Itoa(x)
+		}
+	}
+	operators := map[int]string{addOpcode: "+", multiplyOpcode: "*", lessThanOpcode: "<", equalsOpcode: "=="}
+	return fmt.Sprintf("%s %s %s", d.operand(a), operators[in.Opcode], d.operand(b))
+}
+
+// statement returns the pseudo-code for an instruction which doesn't transfer control
+func (d *decompiler) statement(in Instruction) string {
+	switch in.Opcode {
+	case outputOpcode:
+		return fmt.Sprintf("output(%s)", d.operand(in.Operands[0]))
+	case relativeAdjustOpcode:
+		return fmt.Sprintf("rb += %s", d.operand(in.Operands[0]))
+	case haltOpcode:
+		return "halt()"
+	default:
+		destination := in.Operands[opcodes[in.Opcode].destination]
+		return fmt.Sprintf("%s = %s", d.operand(destination), d.value(in))
+	}
+}
+
+// condition returns the condition under which a conditional jump is taken, or not taken if negate is set
+func (d *decompiler) condition(in Instruction, negate bool) string {
+	operator := "!="
+	if (in.Opcode == jumpIfFalseOpcode) != negate {
+		operator = "=="
+	}
+	return fmt.Sprintf("%s %s 0", d.operand(in.Operands[0]), operator)
+}
+
+// body writes the statements of a block, leaving out any final jump and the prologue and epilogue
+// of the function. A call is written as the function call, along with the values stored into the
+// callee's frame just before it as arguments.
+func (d *decompiler) body(b *BasicBlock, indent int) {
+	instructions := b.Instructions
+	if b.Start == d.function && d.frame > 0 {
+		instructions = instructions[1:]
+	}
+
+	var call string
+	last := b.Last()
+	if last.Opcode == jumpIfTrueOpcode || last.Opcode == jumpIfFalseOpcode {
+		end := len(instructions) - 1
+		if end > 0 && isCall(instructions[end-1], true, last) {
+			args, start := callArguments(instructions)
+			end = start
+			function := last.Operands[1].Value
+			var values []string
+			for _, slot := range d.params[function] {
+				if in, ok := args[slot]; ok {
+					values = append(values, d.value(in))
+				} else {
+					values = append(values, "_")
+				}
+			}
+			call = fmt.Sprintf("%s(%s)", decompiledName(function), strings.Join(values, ", "))
+		} else if b.Return && end > 0 {
+			if in := instructions[end-1]; in.Opcode == relativeAdjustOpcode && in.Operands[0] == (Operand{Value: -d.frame, Mode: immediateMode}) {
+				end--
+			}
+		}
+		instructions = instructions[:end]
+	}
+
+	for _, in := range instructions {
+		d.line(indent, "%s", d.statement(in))
+	}
+	if call != "" {
+		d.line(indent, "%s", call)
+	}
+}
+
+// callArguments returns the instructions storing the arguments of the call ending instructions,
+// keyed by the slot above the relative base each one stores to, and the index of the first of
+// them. Arguments are the run of stores to distinct slots straight before the return address.
+func callArguments(instructions []Instruction) (map[int]Instruction, int) {
+	args := map[int]Instruction{}
+	start := len(instructions) - 2
+	for start > 0 {
+		in := instructions[start-1]
+		info := opcodes[in.Opcode]
+		if info.destination < 0 {
+			break
+		}
+		slot := in.Operands[info.destination]
+		if _, ok := args[slot.Value]; ok || slot.Mode != relativeMode || slot.Value < 1 {
+			break
+		}
+		args[slot.Value] = in
+		start--
+	}
+	return args, start
+}
+
+// callParams returns the slots each function is passed arguments in, over all of its calls
+func callParams(blocks []*BasicBlock) map[int][]int {
+	params := map[int][]int{}
+	seen := map[[2]int]bool{}
+	for _, b := range blocks {
+		if !b.isCall() {
+			continue
+		}
+		function := b.Last().Operands[1].Value
+		args, _ := callArguments(b.Instructions)
+		for slot := range args {
+			if !seen[[2]int{function, slot}] {
+				seen[[2]int{function, slot}] = true
+				params[function] = append(params[function], slot)
+			}
+		}
+	}
+	for _, slots := range params {
+		sort.Ints(slots)
+	}
+	return params
+}
+
+// loopEnd returns the index of the last block which jumps back to blocks[i], or -1 if none do
+func loopEnd(blocks []*BasicBlock, i int) int {
+	for j := len(blocks) - 1; j >= i; j-- {
+		last := blocks[j].Last()
+		if last.Opcode != jumpIfTrueOpcode && last.Opcode != jumpIfFalseOpcode {
+			continue
+		}
+		_, ever := isUnconditionalJump(last)
+		target := last.Operands[1]
+		if ever && target.Mode == immediateMode && target.Value == blocks[i].Start && !blocks[j].isCall() {
+			return j
+		}
+	}
+	return -1
+}
+
+// isCall returns whether the block ends in a function call
+func (b *BasicBlock) isCall() bool {
+	n := len(b.Instructions)
+	return n > 1 && isCall(b.Instructions[n-2], true, b.Instructions[n-1])
+}
+
+// firstAt returns the index of the first block starting at or after address
+func firstAt(blocks []*BasicBlock, address int) int {
+	return sort.Search(len(blocks), func(i int) bool {
+		return blocks[i].Start >= address
+	})
+}
+
+// emit writes a run of blocks, in address order, as structured code. follow is where control goes
+// after the last block, so a jump there from the last block can be left out, the same as a jump
+// from any other block to the one after it.
+func (d *decompiler) emit(blocks []*BasicBlock, indent int, loop *loopContext, follow int) {
+	end := blocks[len(blocks)-1].End
+
+	for i := 0; i < len(blocks); {
+		b := blocks[i]
+		if loop == nil || loop.header != b.Start {
+			if j := loopEnd(blocks, i); j >= 0 {
+				inner := &loopContext{header: b.Start, exit: blocks[j].End, backEdge: blocks[j].Start}
+				back := blocks[j].Last()
+				if always, _ := isUnconditionalJump(back); always {
+					d.line(indent, "loop {")
+					d.emit(blocks[i:j+1], indent+1, inner, b.Start)
+					d.line(indent, "}")
+				} else {
+					d.line(indent, "do {")
+					d.emit(blocks[i:j+1], indent+1, inner, b.Start)
+					d.line(indent, "} while (%s)", d.condition(back, false))
+				}
+				i = j + 1
+				continue
+			}
+		}
+
+		d.lines = append(d.lines, decompiledLine{indent: indent, label: b.Start})
+		d.body(b, indent)
+		last := b.Last()
+		isLast := i == len(blocks)-1
+		next := follow
+		if !isLast {
+			next = blocks[i+1].Start
+		}
+		fallsThrough := true
+
+		switch {
+		case last.Opcode == haltOpcode:
+			fallsThrough = false
+		case last.Opcode != jumpIfTrueOpcode && last.Opcode != jumpIfFalseOpcode, b.isCall():
+		case b.ComputedJump:
+			if b.Return {
+				d.line(indent, "return")
+			} else {
+				d.line(indent, "goto *%s", d.operand(last.Operands[1]))
+			}
+			fallsThrough = false
+		default:
+			always, ever := isUnconditionalJump(last)
+			if !ever {
+				break
+			}
+			target := last.Operands[1].Value
+			condition := ""
+			if !always {
+				condition = d.condition(last, false)
+			}
+			fallsThrough = !always
+
+			switch {
+			case loop != nil && b.Start == loop.backEdge && target == loop.header:
+				// Written by the loop itself
+				fallsThrough = false
+			case always && target == next:
+			case loop != nil && target == loop.header:
+				d.control(indent, condition, "continue")
+			case loop != nil && target == loop.exit:
+				d.control(indent, condition, "break")
+			case !always && target > b.End && target <= end && i+1 < len(blocks) && blocks[i+1].Start == b.End:
+				i = d.emitIf(blocks, i, indent, loop, target, end)
+				continue
+			case !always && target == b.End:
+			default:
+				d.gotoLine(indent, condition, target)
+			}
+		}
+
+		if fallsThrough && next != b.End {
+			d.gotoLine(indent, "", b.End)
+		}
+		i++
+	}
+}
+
+func (d *decompiler) control(indent int, condition string, keyword string) {
+	if condition == "" {
+		d.line(indent, "%s", keyword)
+	} else {
+		d.line(indent, "if (%s) %s", condition, keyword)
+	}
+}
+
+// emitIf writes blocks[i], which ends with a conditional jump forward to target, as an if
+// statement, with an else branch if the code it skips ends by jumping over the code at target.
+// It returns the index of the first block after the if statement.
+func (d *decompiler) emitIf(blocks []*BasicBlock, i int, indent int, loop *loopContext, target int, end int) int {
+	jump := blocks[i].Last()
+	k := firstAt(blocks, target)
+	then := blocks[i+1 : k]
+
+	d.line(indent, "if (%s) {", d.condition(jump, true))
+	last := then[len(then)-1]
+	join := -1
+	if always, _ := isUnconditionalJump(last.Last()); always && !last.isCall() && !last.ComputedJump {
+		join = last.Last().Operands[1].Value
+	}
+	if join <= target || join > end || (loop != nil && last.Start == loop.backEdge) {
+		d.emit(then, indent+1, loop, target)
+		d.line(indent, "}")
+		return k
+	}
+
+	m := firstAt(blocks, join)
+	d.emit(then, indent+1, loop, join)
+	if m > k {
+		d.line(indent, "} else {")
+		d.emit(blocks[k:m], indent+1, loop, join)
+	}
+	d.line(indent, "}")
+	return m
+}
@@ -0,0 +1,52 @@
+package intcode
+
+import (
+	"bytes"
+	"testing"
+)
+
+var decompileCases = []struct {
+	name     string
+	program  []int
+	expected string
+}{
+	{"loop", countdown, `func main() {
+    mem[16] = input()
+    do {
+        mem[17] = mem[17] + mem[16]
+        mem[16] = mem[16] + -1
+    } while (mem[16] != 0)
+    output(mem[17])
+    halt()
+}
+`},
+	{"call", caller, `func main() {
+    rb += 37
+    mem[36] = input()
+    f0025(mem[36])
+    output(mem[36])
+    mem[36] = 24 + mem[36]
+    halt()
+}
+
+func f0025(local1) {  // frame 2
+    mem[36] = local1 * 2
+    return
+}
+`},
+}
+
+// TestWriteDecompiled compares the decompiled pseudo-code of some small programs with what it should be
+func TestWriteDecompiled(t *testing.T) {
+	for _, c := range decompileCases {
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := WriteDecompiled(&out, c.program); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != c.expected {
+				t.Errorf("got\n%s\nexpected\n%s", out.String(), c.expected)
+			}
+		})
+	}
+}
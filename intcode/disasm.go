@@ -0,0 +1,313 @@
+package intcode
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// dataPerLine is the most values a single data directive will hold
+const dataPerLine = 8
+
+// Operand is a decoded instruction parameter
+type Operand struct {
+	Value int
+	Mode  int
+	// Label is the symbolic name for Value, if it refers to a labelled address
+	Label string
+}
+
+func (o Operand) String() string {
+	value := strconv.Itoa(o.Value)
+	if o.Label != "" {
+		value = o.Label
+	}
+
+	switch o.Mode {
+	case positionMode:
+		return "[" + value + "]"
+	case immediateMode:
+		return "#" + value
+	case relativeMode:
+		if o.Value < 0 {
+			return "rb" + value
+		}
+		return "rb+" + value
+	default:
+		return "?" + value
+	}
+}
+
+// Instruction is a single line of a disassembly: either a decoded instruction or a data directive
+type Instruction struct {
+	Address  int
+	Label    string
+	Opcode   int
+	Mnemonic string
+	Operands []Operand
+	// Data holds the raw values for a data directive. Mnemonic is "data" when this is set.
+	Data []int
+}
+
+// Len returns the number of tape cells covered by the line
+func (in Instruction) Len() int {
+	if in.Data != nil {
+		return len(in.Data)
+	}
+	return len(in.Operands) + 1
+}
+
+// IsData returns whether the line is a data directive rather than an instruction
+func (in Instruction) IsData() bool {
+	return in.Data != nil
+}
+
+func (in Instruction) String() string {
+	var args []string
+	if in.IsData() {
+		for _, x := range in.Data {
+			args = append(args, strconv.Itoa(x))
+		}
+	} else {
+		for _, o := range in.Operands {
+			args = append(args, o.String())
+		}
+	}
+
+	if len(args) == 0 {
+		return in.Mnemonic
+	}
+	return in.Mnemonic + " " + strings.Join(args, ", ")
+}
+
+// decodeInstruction decodes the instruction at address in data. ok is false if the value
+// there is not a valid instruction, the instruction runs past the end of data, its destination
+// is immediate, or the instruction is not in the canonical form the assembler would produce for it.
+func decodeInstruction(data []int, address int) (in Instruction, ok bool) {
+	value := data[address]
+	opcode, err := decodeOpcode(value)
+	if err != nil {
+		return in, false
+	}
+	info, ok := opcodes[opcode]
+	if !ok || address+info.paramCount >= len(data) {
+		return in, false
+	}
+
+	in = Instruction{Address: address, Opcode: opcode, Mnemonic: info.mnemonic}
+	canonical := opcode
+	scale := 100
+	for i := 0; i < info.paramCount; i++ {
+		mode := decodeMode(value, i)
+		if mode > relativeMode || (mode == immediateMode && i == info.destination) {
+			return in, false
+		}
+		in.Operands = append(in.Operands, Operand{Value: data[address+i+1], Mode: mode})
+		canonical += mode * scale
+		scale *= 10
+	}
+
+	return in, canonical == value
+}
+
+// instructionAt decodes the instruction at address from the tape's current memory.
+// If there is no valid instruction there, a single data value is returned.
+func (t *Tape) instructionAt(address int) Instruction {
+	window := make([]int, 4)
+	for i := range window {
+		window[i], _ = t.data.Read(address + i)
+	}
+
+	in, ok := decodeInstruction(window, 0)
+	if !ok {
+		return Instruction{Address: address, Mnemonic: "data", Data: window[:1]}
+	}
+	in.Address = address
+	return in
+}
+
+// isUnconditionalJump returns whether a decoded jump always jumps, and whether it can ever jump
+func isUnconditionalJump(in Instruction) (always bool, ever bool) {
+	if in.Opcode != jumpIfTrueOpcode && in.Opcode != jumpIfFalseOpcode {
+		return false, false
+	}
+	test := in.Operands[0]
+	if test.Mode != immediateMode {
+		return false, true
+	}
+	jumps := (test.Value != 0) == (in.Opcode == jumpIfTrueOpcode)
+	return jumps, jumps
+}
+
+// findCode walks data from each entry point, following fall-through and immediate jump targets,
+// and returns the instructions which can be reached, keyed by address
+func findCode(data []int, entries []int) map[int]Instruction {
+	code := map[int]Instruction{}
+	// owner maps every cell covered by a reached instruction to that instruction's address
+	owner := map[int]int{}
+
+	previous := map[int]Instruction{}
+	work := append([]int(nil), entries...)
+	for len(work) > 0 {
+		address := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		if address < 0 || address >= len(data) {
+			continue
+		}
+		if _, seen := owner[address]; seen {
+			continue
+		}
+
+		in, ok := decodeInstruction(data, address)
+		if !ok {
+			continue
+		}
+		overlaps := false
+		for i := address; i < address+in.Len(); i++ {
+			if _, seen := owner[i]; seen {
+				overlaps = true
+			}
+		}
+		if overlaps {
+			continue
+		}
+
+		code[address] = in
+		for i := address; i < address+in.Len(); i++ {
+			owner[i] = address
+		}
+
+		next := address + in.Len()
+		switch in.Opcode {
+		case haltOpcode:
+		case jumpIfTrueOpcode, jumpIfFalseOpcode:
+			always, ever := isUnconditionalJump(in)
+			if target := in.Operands[1]; ever && target.Mode == immediateMode {
+				work = append(work, target.Value)
+			}
+			if !always {
+				previous[next] = in
+				work = append(work, next)
+			} else if prev, ok := previous[address]; ok && storesReturnAddress(prev, next) {
+				// The instruction before an unconditional jump saving the address after the jump
+				// on the stack is how calls are made, so the code after the jump is where the call returns to
+				work = append(work, next)
+			}
+		default:
+			previous[next] = in
+			work = append(work, next)
+		}
+	}
+
+	return code
+}
+
+// storesReturnAddress returns whether in is how a call saves the address it returns to: an add or
+// multiply copying the immediate value x to an address relative to the relative base
+func storesReturnAddress(in Instruction, x int) bool {
+	identity := 0
+	switch in.Opcode {
+	case addOpcode:
+	case multiplyOpcode:
+		identity = 1
+	default:
+		return false
+	}
+	if in.Operands[2].Mode != relativeMode {
+		return false
+	}
+	value := Operand{Value: x, Mode: immediateMode}
+	other := Operand{Value: identity, Mode: immediateMode}
+	a, b := in.Operands[0], in.Operands[1]
+	return (a == value && b == other) || (a == other && b == value)
+}
+
+// Disassemble decodes a tape image, as returned by GetTapeData. Code is discovered by walking the
+// program from address 0 (and any extra entry points given), and anything which is never reached
+// is emitted as data. Addresses used as jump targets or memory operands are given labels.
+func Disassemble(data []int, entries ...int) []Instruction {
+	code := findCode(data, append([]int{0}, entries...))
+
+	// Find every address which is referenced, so that data lines can be split to label them
+	referenced := map[int]bool{}
+	for _, in := range code {
+		for i, o := range in.Operands {
+			isTarget := (in.Opcode == jumpIfTrueOpcode || in.Opcode == jumpIfFalseOpcode) && i == 1 && o.Mode == immediateMode
+			if (isTarget || o.Mode == positionMode) && o.Value >= 0 && o.Value < len(data) {
+				referenced[o.Value] = true
+			}
+		}
+	}
+	for _, entry := range entries {
+		referenced[entry] = true
+	}
+
+	var listing []Instruction
+	for address := 0; address < len(data); {
+		if in, ok := code[address]; ok {
+			listing = append(listing, in)
+			address += in.Len()
+			continue
+		}
+
+		line := Instruction{Address: address, Mnemonic: "data"}
+		for address < len(data) && len(line.Data) < dataPerLine {
+			if _, ok := code[address]; ok || (len(line.Data) > 0 && referenced[address]) {
+				break
+			}
+			line.Data = append(line.Data, data[address])
+			address++
+		}
+		listing = append(listing, line)
+	}
+
+	labels := map[int]string{}
+	for i := range listing {
+		in := &listing[i]
+		if !referenced[in.Address] {
+			continue
+		}
+		prefix := "L"
+		if in.IsData() {
+			prefix = "D"
+		}
+		in.Label = prefix + strconv.Itoa(in.Address)
+		labels[in.Address] = in.Label
+	}
+
+	for _, in := range listing {
+		for i := range in.Operands {
+			o := &in.Operands[i]
+			if o.Mode == relativeMode {
+				continue
+			}
+			isTarget := (in.Opcode == jumpIfTrueOpcode || in.Opcode == jumpIfFalseOpcode) && i == 1
+			if o.Mode == positionMode || isTarget {
+				o.Label = labels[o.Value]
+			}
+		}
+	}
+
+	return listing
+}
+
+// WriteListing writes a disassembly to w, one line per instruction, as address, label and instruction
+func WriteListing(w io.Writer, listing []Instruction) error {
+	for _, in := range listing {
+		if _, err := fmt.Fprintln(w, FormatLine(in)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatLine formats a single line of a disassembly listing
+func FormatLine(in Instruction) string {
+	label := ""
+	if in.Label != "" {
+		label = in.Label + ":"
+	}
+	return fmt.Sprintf("%04d  %-8s%s", in.Address, label, in)
+}
@@ -0,0 +1,59 @@
+package intcode
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDisassembleRoundTrip checks that assembling a program's disassembly listing gives back the
+// same tape image
+func TestDisassembleRoundTrip(t *testing.T) {
+	programs := map[string][]int{
+		"day2 example":  day2Example,
+		"large compare": largeCompare,
+		"quine":         quine,
+		"day7 example":  day7Example,
+		"countdown":     countdown,
+		"caller":        caller,
+	}
+	for name, program := range programs {
+		t.Run(name, func(t *testing.T) {
+			var listing bytes.Buffer
+			if err := WriteListing(&listing, Disassemble(program)); err != nil {
+				t.Fatal(err)
+			}
+			data, err := Assemble(&listing)
+			if err != nil {
+				t.Fatalf("%v assembling\n%s", err, listing.String())
+			}
+			if !reflect.DeepEqual(data, program) {
+				t.Errorf("got %v, expected %v", data, program)
+			}
+		})
+	}
+}
+
+// TestDisassembleLabels checks that jump targets and memory operands are labelled, and that
+// unreached cells are listed as data
+func TestDisassembleLabels(t *testing.T) {
+	var listing bytes.Buffer
+	if err := WriteListing(&listing, Disassemble(countdown)); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(listing.String()), "\n")
+	expected := []string{
+		"0000          in [D16]",
+		"0002  L2:     add [D17], [D16], [D17]",
+		"0006          add [D16], #-1, [D16]",
+		"0010          jnz [D16], #L2",
+		"0013          out [D17]",
+		"0015          hlt",
+		"0016  D16:    data 0",
+		"0017  D17:    data 0",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("got\n%s\nexpected\n%s", strings.Join(lines, "\n"), strings.Join(expected, "\n"))
+	}
+}
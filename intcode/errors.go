@@ -0,0 +1,62 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidOpcode is returned when the instruction at the cursor has an unknown opcode
+	ErrInvalidOpcode = errors.New("invalid opcode")
+	// ErrInvalidMode is returned when a parameter has an unknown mode
+	ErrInvalidMode = errors.New("invalid parameter mode")
+	// ErrOutOfBounds is returned when an instruction reads or writes outside of the tape
+	ErrOutOfBounds = errors.New("address out of bounds")
+	// ErrInputExhausted is returned when an input instruction runs with no input queued
+	ErrInputExhausted = errors.New("input exhausted")
+	// ErrOverflow is returned when a value doesn't fit in an int, and the tape's word mode doesn't allow that
+	ErrOverflow = errors.New("overflow")
+	// ErrStepLimit is returned, wrapped in a *LimitError, when a tape has run as many instructions as it is allowed to
+	ErrStepLimit = errors.New("step limit reached")
+	// ErrDeadlock is returned when a Network goes idle with machines still waiting for input
+	ErrDeadlock = errors.New("network deadlocked")
+	// ErrNoRoute is returned when a packet is sent to an address with nothing at it
+	ErrNoRoute = errors.New("no route to address")
+	// ErrNoHistory is returned by StepBack when there is no instruction left in the tape's history to undo
+	ErrNoHistory = errors.New("no history")
+)
+
+// Error is returned by the tape when an instruction fails. It wraps one of the Err* values above,
+// so errors.Is can be used to tell failures apart, and records the state of the tape at the time.
+type Error struct {
+	Err          error
+	Cursor       int
+	Instruction  int
+	RelativeBase int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("intcode: %v at %d (instruction %d, relative base %d)", e.Err, e.Cursor, e.Instruction, e.RelativeBase)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// LimitError is returned when a run is abandoned before the tape halts, because the tape reached
+// its instruction limit or the run's context was done. It wraps ErrStepLimit or the context's
+// error, and records where the tape stopped. The tape is left ready to run the instruction at
+// Cursor, so it can carry on if the limit is raised.
+type LimitError struct {
+	Err          error
+	Cursor       int
+	Instructions int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("intcode: %v at %d after %d instructions", e.Err, e.Cursor, e.Instructions)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,237 @@
+package intcode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// fuzzMode is one of the ways checkTape runs a tape. They should all behave exactly the same.
+type fuzzMode struct {
+	name string
+	tape func(data []int, steps int) Tape
+}
+
+var fuzzModes = []fuzzMode{
+	{"interpreted", func(data []int, steps int) Tape {
+		return CreateTapeCopy(data)
+	}},
+	{"uncached", func(data []int, steps int) Tape {
+		t := CreateTapeCopy(data)
+		t.SetDecodeCache(false)
+		return t
+	}},
+	{"compiled", func(data []int, steps int) Tape {
+		return Compile(data).NewTape()
+	}},
+	// With a history long enough for the whole run, which checkTape then undoes
+	{"history", func(data []int, steps int) Tape {
+		t := Compile(data).NewTape()
+		t.SetHistoryLimit(steps)
+		return t
+	}},
+}
+
+// fuzzErrors are the failures a tape may report. Anything else is a bug.
+var fuzzErrors = []error{ErrInvalidOpcode, ErrInvalidMode, ErrOutOfBounds, ErrInputExhausted, ErrOverflow, ErrStepLimit}
+
+// fuzzResult is the state a tape is left in by a fuzz run
+type fuzzResult struct {
+	err          string
+	cursor       int
+	relativeBase int
+	instructions int
+	waiting      bool
+	output       []int
+	memory       *memory
+}
+
+func (r fuzzResult) String() string {
+	return fmt.Sprintf("cursor %d, relative base %d, %d instructions, output %v, error %q", r.cursor, r.relativeBase, r.instructions, r.output, r.err)
+}
+
+func (r fuzzResult) equal(other fuzzResult) bool {
+	return r.err == other.err && r.cursor == other.cursor && r.relativeBase == other.relativeBase &&
+		r.instructions == other.instructions && r.waiting == other.waiting &&
+		reflect.DeepEqual(r.output, other.output) && r.memory.equal(other.memory)
+}
+
+// checkTape runs a tape image with the given input until it halts, fails, or has run steps
+// instructions, in each of the ways a tape can be run: interpreted, without the decode cache,
+// compiled, and recording its history. It returns an error describing the first problem found:
+// a panic, a failure which isn't one of the package's errors or doesn't say where the tape
+// stopped, the runs not ending in exactly the same state, or undoing the recorded run not giving
+// back the tape it started from.
+func checkTape(data []int, input []int, steps int) error {
+	var expected fuzzResult
+	for i, mode := range fuzzModes {
+		t := mode.tape(data, steps)
+		result, err := fuzzRun(&t, input, steps)
+		if err != nil {
+			return fmt.Errorf("%s: %w", mode.name, err)
+		}
+		if i == 0 {
+			expected = result
+		} else if !result.equal(expected) {
+			return fmt.Errorf("%s: ended with %v, but %s ended with %v", mode.name, result, fuzzModes[0].name, expected)
+		}
+
+		if t.HistoryLength() > 0 {
+			if err := fuzzUndo(&t, data, input); err != nil {
+				return fmt.Errorf("%s: %w", mode.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// fuzzRun runs a tape, recovering from any panic
+func fuzzRun(t *Tape, input []int, steps int) (result fuzzResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	t.SetInstructionLimit(steps)
+	for _, x := range input {
+		t.Input(x)
+	}
+	if runErr := t.RunUntilHalt(); runErr != nil {
+		if err := checkFuzzError(t, runErr); err != nil {
+			return result, err
+		}
+		result.err = runErr.Error()
+	}
+
+	return fuzzResult{
+		err:          result.err,
+		cursor:       t.cursor,
+		relativeBase: t.relativeBase,
+		instructions: t.instructions,
+		waiting:      t.waiting,
+		output:       t.PendingOutput(),
+		memory:       t.data,
+	}, nil
+}
+
+// checkFuzzError checks that a tape failed with one of the package's errors, which says where it stopped
+func checkFuzzError(t *Tape, err error) error {
+	known := false
+	for _, e := range fuzzErrors {
+		known = known || errors.Is(err, e)
+	}
+	if !known {
+		return fmt.Errorf("unexpected error %v", err)
+	}
+
+	var tapeErr *Error
+	var limitErr *LimitError
+	switch {
+	case errors.As(err, &tapeErr):
+		if tapeErr.Cursor != t.cursor || tapeErr.RelativeBase != t.relativeBase {
+			return fmt.Errorf("error %v, but the tape is at %d with relative base %d", err, t.cursor, t.relativeBase)
+		}
+	case errors.As(err, &limitErr):
+		if limitErr.Cursor != t.cursor || limitErr.Instructions != t.instructions {
+			return fmt.Errorf("error %v, but the tape is at %d after %d instructions", err, t.cursor, t.instructions)
+		}
+	default:
+		return fmt.Errorf("error %v doesn't say where the tape stopped", err)
+	}
+	return nil
+}
+
+// fuzzUndo steps a tape back through its whole history, and checks it ends up as it started
+func fuzzUndo(t *Tape, data []int, input []int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic stepping back: %v", r)
+		}
+	}()
+
+	for t.HistoryLength() > 0 {
+		if _, err := t.StepBack(); err != nil {
+			return fmt.Errorf("stepping back: %w", err)
+		}
+	}
+	if t.cursor != 0 || t.relativeBase != 0 {
+		return fmt.Errorf("undoing the run left the tape at %d with relative base %d", t.cursor, t.relativeBase)
+	}
+	if !t.data.equal(newMemory(data)) {
+		return fmt.Errorf("undoing the run didn't restore the tape's memory")
+	}
+	if pending := t.PendingInput(); len(pending) != len(input) || (len(input) > 0 && !reflect.DeepEqual(pending, input)) {
+		return fmt.Errorf("undoing the run left input %v, expected %v", pending, input)
+	}
+	if pending := t.PendingOutput(); len(pending) > 0 {
+		return fmt.Errorf("undoing the run left output %v", pending)
+	}
+	return nil
+}
+
+// fuzzSteps is the most instructions a fuzzed tape runs
+const fuzzSteps = 1000
+
+// packValues packs values as varints, which is how FuzzTape is given tape images and input
+func packValues(values []int) []byte {
+	var b []byte
+	for _, x := range values {
+		b = binary.AppendVarint(b, int64(x))
+	}
+	return b
+}
+
+// unpackValues unpacks values packed by packValues, ignoring anything left over at the end
+func unpackValues(b []byte) []int {
+	var values []int
+	for len(b) > 0 {
+		x, n := binary.Varint(b)
+		if n <= 0 {
+			break
+		}
+		values = append(values, int(x))
+		b = b[n:]
+	}
+	return values
+}
+
+// fuzzSeeds are the tapes FuzzTape starts from: the examples, and programs which once failed
+var fuzzSeeds = []struct {
+	data, input []int
+}{
+	{runaway, nil},
+	{overflowing, nil},
+	{immediateDestination, nil},
+	{echo, []int{7}},
+	{quine, nil},
+	// The params of an instruction at the very top of memory are past the last address
+	{[]int{1101, 1, 0, math.MaxInt64, 1105, 1, math.MaxInt64}, nil},
+	// Relative mode past the start of memory
+	{[]int{109, -5, 204, 0, 99}, nil},
+	// An invalid opcode, and an invalid mode
+	{[]int{1, 0, 0, 0, 42}, nil},
+	{[]int{30001, 0, 0, 0, 99}, nil},
+}
+
+// FuzzTape checks that every way of running a tape behaves exactly the same, whatever the tape
+func FuzzTape(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(packValues(seed.data), packValues(seed.input))
+	}
+	for _, c := range exampleCases {
+		f.Add(packValues(c.program), packValues(c.input))
+	}
+	for _, c := range amplifierCases {
+		f.Add(packValues(c.program), packValues([]int{c.phases[0], 0}))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, input []byte) {
+		if err := checkTape(unpackValues(data), unpackValues(input), fuzzSteps); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
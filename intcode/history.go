@@ -0,0 +1,146 @@
+package intcode
+
+// undoEntry is everything needed to undo one instruction
+type undoEntry struct {
+	// step is a copy of the step record, with its own slices so ring entries can reuse them
+	step Step
+	// input is set if the instruction consumed an input value, which was step.Writes[0].Value
+	input bool
+	// output is set if the instruction wrote output to the tape's queue, and pending is how many
+	// values were left in the queue afterwards
+	output  bool
+	pending int
+	// outputCount and lastOutput are as they were before the instruction ran
+	outputCount int
+	lastOutput  int
+}
+
+// history is a bounded log of the instructions a tape has run, held in a ring buffer so the
+// oldest entries are dropped once the limit is reached
+type history struct {
+	entries []undoEntry
+	limit   int
+	// next is where the next entry goes, and length is how many entries are held
+	next   int
+	length int
+	// outputCount and lastOutput are saved from the tape before each instruction runs
+	outputCount int
+	lastOutput  int
+}
+
+// emptied returns a history with the same limit and no entries, or nil for a nil history
+func (h *history) emptied() *history {
+	if h == nil {
+		return nil
+	}
+	return &history{limit: h.limit}
+}
+
+// push returns the entry to fill in for the next instruction
+func (h *history) push() *undoEntry {
+	if len(h.entries) < h.limit {
+		h.entries = append(h.entries, undoEntry{})
+	}
+	e := &h.entries[h.next]
+	h.next = (h.next + 1) % h.limit
+	if h.length < h.limit {
+		h.length++
+	}
+	return e
+}
+
+// pop removes and returns the most recent entry
+func (h *history) pop() (*undoEntry, bool) {
+	if h.length == 0 {
+		return nil, false
+	}
+	h.next = (h.next - 1 + h.limit) % h.limit
+	h.length--
+	return &h.entries[h.next], true
+}
+
+// SetHistoryLimit keeps a log of the last limit instructions the tape runs, so they can be undone
+// with StepBack. Each entry holds the cursor, relative base, memory writes and input and output of
+// an instruction. A limit of 0 turns the log off. Changing the limit discards the log.
+// Like observers, keeping a log makes the tape run instruction by instruction rather than through
+// compiled blocks. Values too large for an int, in BigWords mode, are not restored by StepBack.
+func (t *Tape) SetHistoryLimit(limit int) {
+	if limit <= 0 {
+		t.history = nil
+		return
+	}
+	t.history = &history{limit: limit}
+}
+
+// HistoryLimit returns the most instructions the tape keeps in its log, or 0 if it keeps none
+func (t Tape) HistoryLimit() int {
+	if t.history == nil {
+		return 0
+	}
+	return t.history.limit
+}
+
+// HistoryLength returns how many instructions can currently be undone with StepBack
+func (t Tape) HistoryLength() int {
+	if t.history == nil {
+		return 0
+	}
+	return t.history.length
+}
+
+// logStep adds the instruction which just ran to the history
+func (t *Tape) logStep() {
+	e := t.history.push()
+	e.step.Operands = append(e.step.Operands[:0], t.step.Operands...)
+	e.step.Reads = append(e.step.Reads[:0], t.step.Reads...)
+	e.step.Writes = append(e.step.Writes[:0], t.step.Writes...)
+	operands, reads, writes := e.step.Operands, e.step.Reads, e.step.Writes
+	e.step = t.step
+	e.step.Operands, e.step.Reads, e.step.Writes = operands, reads, writes
+
+	e.input = t.step.Opcode == inputOpcode && len(t.step.Writes) > 0
+	e.output = t.step.Opcode == outputOpcode && t.sink == nil
+	if e.output {
+		e.pending = len(t.PendingOutput())
+	}
+	e.outputCount, e.lastOutput = t.history.outputCount, t.history.lastOutput
+}
+
+// StepBack undoes the last instruction the tape ran, and returns the step record for it. Memory,
+// the cursor and the relative base are put back as they were, input the instruction consumed is
+// queued again, and output it produced is taken back off the output queue if it is still there.
+// Output already written to a connected Output can't be taken back. ErrNoHistory is returned if
+// the tape isn't keeping a log, or every instruction in it has been undone.
+func (t *Tape) StepBack() (Step, error) {
+	if t.history == nil {
+		return Step{}, ErrNoHistory
+	}
+	e, ok := t.history.pop()
+	if !ok {
+		return Step{}, ErrNoHistory
+	}
+
+	for i := len(e.step.Writes) - 1; i >= 0; i-- {
+		w := e.step.Writes[i]
+		t.data.write(w.Address, w.Previous)
+		t.modified(w.Address)
+	}
+	if e.input {
+		t.input = newQueue(append([]int{e.step.Writes[0].Value}, t.PendingInput()...))
+	}
+	if e.output {
+		if pending := t.PendingOutput(); len(pending) == e.pending {
+			t.output = newQueue(pending[:len(pending)-1])
+		}
+	}
+	t.cursor = e.step.Cursor
+	t.relativeBase = e.step.RelativeBase
+	t.outputCount, t.lastOutput = e.outputCount, e.lastOutput
+	t.waiting = false
+
+	step := e.step
+	step.Operands = append([]int(nil), e.step.Operands...)
+	step.Reads = append([]Access(nil), e.step.Reads...)
+	step.Writes = append([]Access(nil), e.step.Writes...)
+	return step, nil
+}
@@ -0,0 +1,100 @@
+package intcode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestStepBack runs a tape to the end, then undoes it all, checking it's back where it started
+func TestStepBack(t *testing.T) {
+	tape := CreateTapeCopy(largeCompare)
+	tape.SetHistoryLimit(1000)
+	tape.Input(8)
+	if err := tape.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	if output := tape.PendingOutput(); !reflect.DeepEqual(output, []int{1000}) {
+		t.Fatalf("got %v, expected [1000]", output)
+	}
+
+	undone := 0
+	for tape.HistoryLength() > 0 {
+		if _, err := tape.StepBack(); err != nil {
+			t.Fatal(err)
+		}
+		undone++
+	}
+	if undone != tape.instructions {
+		t.Errorf("undid %d instructions, expected %d", undone, tape.instructions)
+	}
+	if _, err := tape.StepBack(); !errors.Is(err, ErrNoHistory) {
+		t.Errorf("got %v stepping back past the start, expected %v", err, ErrNoHistory)
+	}
+	if tape.Cursor() != 0 || !tape.data.equal(newMemory(largeCompare)) {
+		t.Errorf("undoing the run left the tape at %d, or with changed memory", tape.Cursor())
+	}
+	if !reflect.DeepEqual(tape.PendingInput(), []int{8}) || len(tape.PendingOutput()) > 0 {
+		t.Errorf("undoing the run left input %v and output %v, expected [8] and none", tape.PendingInput(), tape.PendingOutput())
+	}
+}
+
+// TestHistoryLimit checks that only the most recent instructions are kept
+func TestHistoryLimit(t *testing.T) {
+	tape := CreateTapeCopy(countdown)
+	tape.SetHistoryLimit(3)
+	tape.Input(5)
+	if err := tape.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	if n := tape.HistoryLength(); n != 3 {
+		t.Fatalf("got %d instructions of history, expected 3", n)
+	}
+	for _, cursor := range []int{13, 10, 6} {
+		step, err := tape.StepBack()
+		if err != nil || step.Cursor != cursor || tape.Cursor() != cursor {
+			t.Fatalf("undid %d (%v) and moved to %d, expected %d", step.Cursor, err, tape.Cursor(), cursor)
+		}
+	}
+	if _, err := tape.StepBack(); !errors.Is(err, ErrNoHistory) {
+		t.Errorf("got %v past the history limit, expected %v", err, ErrNoHistory)
+	}
+}
+
+// TestReverseContinue runs countdown to the end, then goes back through the writes of the
+// counter with a watchpoint, and back to the top of the loop with a breakpoint
+func TestReverseContinue(t *testing.T) {
+	d := newCountdownDebugger(3)
+	if stop := d.Continue(); stop.Reason != StoppedHalt {
+		t.Fatalf("got %v, expected the tape to halt", stop)
+	}
+
+	counter := d.AddWatchpoint(16, false, true)
+	for _, expected := range []Access{{16, 0, 1}, {16, 1, 2}} {
+		stop := d.ReverseContinue()
+		if stop.Reason != StoppedWatchpoint || stop.Watchpoint != counter || stop.Access != expected {
+			t.Fatalf("got %v, expected the write %+v", stop, expected)
+		}
+		// The write is undone, and the instruction which made it is next to run
+		if value, _ := d.Tape().Peek(16); value != expected.Previous || d.Tape().Cursor() != 6 {
+			t.Errorf("counter is %d at %d, expected %d at 6", value, d.Tape().Cursor(), expected.Previous)
+		}
+	}
+	d.Delete(counter.ID)
+
+	loop := d.AddBreakpoint(2)
+	if stop := d.ReverseContinue(); stop.Reason != StoppedBreakpoint || stop.Breakpoint != loop || d.Tape().Cursor() != 2 {
+		t.Fatalf("got %v at %d, expected %v", stop, d.Tape().Cursor(), loop)
+	}
+	d.Delete(loop.ID)
+
+	if stop := d.ReverseContinue(); stop.Reason != StoppedHistoryStart || d.Tape().Cursor() != 0 {
+		t.Fatalf("got %v at %d, expected the start of the history", stop, d.Tape().Cursor())
+	}
+	if stop := d.Continue(); stop.Reason != StoppedHalt {
+		t.Fatalf("got %v running forwards again, expected the tape to halt", stop)
+	}
+	if output := d.Tape().PendingOutput(); !reflect.DeepEqual(output, []int{6}) {
+		t.Errorf("got output %v, expected [6]", output)
+	}
+}
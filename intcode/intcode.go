@@ -2,8 +2,11 @@ package intcode
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"intqueue"
-	"log"
+	"io"
+	"math/big"
 	"strconv"
 	"strings"
 	"util/datafile"
@@ -28,88 +31,208 @@ const (
 	relativeMode  = 2
 )
 
+// opcodeInfo describes the shape of an instruction
+type opcodeInfo struct {
+	mnemonic   string
+	paramCount int
+	// destination is the index of the param which is written to, or -1 if nothing is written
+	destination int
+}
+
+var opcodes = map[int]opcodeInfo{
+	addOpcode:            {"add", 3, 2},
+	multiplyOpcode:       {"mul", 3, 2},
+	inputOpcode:          {"in", 1, 0},
+	outputOpcode:         {"out", 1, -1},
+	jumpIfTrueOpcode:     {"jnz", 2, -1},
+	jumpIfFalseOpcode:    {"jz", 2, -1},
+	lessThanOpcode:       {"lt", 3, 2},
+	equalsOpcode:         {"eq", 3, 2},
+	relativeAdjustOpcode: {"arb", 1, -1},
+	haltOpcode:           {"hlt", 0, -1},
+}
+
 type param struct {
 	value int
 	mode  int
 }
 
 type Tape struct {
-	data         []int
+	data         *memory
 	cursor       int
 	input        intqueue.Queue
 	output       intqueue.Queue
+	source       Input
+	sink         Output
+	waiting      bool
+	outputCount  int
+	lastOutput   int
 	relativeBase int
+	observers    []Observer
+	step         Step
+	// cache holds decoded instructions by address, see decode
+	cache    []decoded
+	uncached bool
+	// compiled is set for tapes created from a Program
+	compiled *compiledTape
+	words    WordMode
+	// history is the log used by StepBack, if the tape is keeping one
+	history *history
+	// instructions counts the instructions the tape has run, and maxInstructions is the most it
+	// may run, or 0 for no limit
+	instructions    int
+	maxInstructions int
+	// bigs holds the cells whose values are too large for an int, in BigWords mode
+	bigs map[int]*big.Int
 }
 
-func getChar(s string, pos int) byte {
-	if pos >= len(s) || pos < 0 {
-		return '0'
-	}
+func (t Tape) IsHalted() bool {
+	return t.halted()
+}
 
-	return s[len(s)-pos-1]
+// halted is IsHalted for the run loops, which avoids copying the tape on every instruction
+func (t *Tape) halted() bool {
+	return t.cursor >= 0 && t.data.read(t.cursor) == haltOpcode
 }
 
-func decodeOpcode(value int) int {
-	valueString := strconv.Itoa(value)
+// fault wraps err with the state of the tape at the instruction starting at cursor
+func (t Tape) fault(err error, cursor int) error {
+	instruction, _ := t.data.Read(cursor)
+	return &Error{Err: err, Cursor: cursor, Instruction: instruction, RelativeBase: t.relativeBase}
+}
 
-	opcodeString := string(getChar(valueString, 1)) + string(getChar(valueString, 0))
-	opcode, err := strconv.Atoi(opcodeString)
-	if err != nil {
-		log.Fatal(err)
-	}
+// Value returns the value/opcode at the cursor
+func (t Tape) Value() int {
+	value, _ := t.data.Read(t.cursor)
+	return value
+}
 
-	return opcode
+// Resolve returns the value of a parameter, based on its mode
+func (t *Tape) Resolve(p param) (int, error) {
+	switch p.mode {
+	case positionMode:
+		return t.data.Read(p.value)
+	case immediateMode:
+		return p.value, nil
+	case relativeMode:
+		return t.data.Read(p.value + t.relativeBase)
+	default:
+		return 0, fmt.Errorf("%w: %d", ErrInvalidMode, p.mode)
+	}
 }
 
-func (t Tape) IsHalted() bool {
-	return t.Value() == haltOpcode
+// reference returns the address of a parameter, so that it can be used as a destination.
+// Immediate params have no address, so they can't be destinations.
+func (t *Tape) reference(p param) (int, error) {
+	address := p.value
+	switch p.mode {
+	case positionMode:
+	case immediateMode:
+		return 0, fmt.Errorf("%w: immediate destination %d", ErrInvalidMode, p.value)
+	case relativeMode:
+		address += t.relativeBase
+	default:
+		return 0, fmt.Errorf("%w: %d", ErrInvalidMode, p.mode)
+	}
+
+	if err := checkAddress(address); err != nil {
+		return 0, err
+	}
+	return address, nil
 }
 
-// GetParams extracts [paramCount] params, and advances the cursor to the instruction that will happen next
-func (t *Tape) GetParams(instructionValue int, paramCount int) []param {
-	instructionString := strconv.Itoa(instructionValue)
-	params := make([]param, paramCount)
+// resolveAll resolves each param in order, stopping at the first one which fails.
+// The param at index destination (if any) is resolved to the address it refers to.
+func (t *Tape) resolveAll(params []param, destination int) ([3]int, error) {
+	var values [3]int
+	for i, p := range params {
+		if i == destination {
+			address, err := t.reference(p)
+			if err != nil {
+				return values, err
+			}
+			values[i] = address
+			continue
+		}
 
-	cursor := t.cursor + 1
-	for i := 0; i < paramCount; i++ {
-		paramValue := t.data[cursor+i]
-		modeString := getChar(instructionString, i+2)
-		mode, err := strconv.Atoi(string(modeString))
+		value, err := t.Resolve(p)
 		if err != nil {
-			log.Fatal(err)
+			return values, err
+		}
+		values[i] = value
+		if t.recording() && p.mode != immediateMode {
+			address, _ := t.reference(p)
+			t.step.Reads = append(t.step.Reads, Access{Address: address, Value: value})
 		}
-		params[i] = param{paramValue, mode}
 	}
+	if t.recording() {
+		t.step.Operands = append(t.step.Operands, values[:len(params)]...)
+	}
+	return values, nil
+}
 
-	t.cursor += paramCount + 1
+// store writes the result of an instruction to its destination param, which resolveAll has checked
+func (t *Tape) store(p param, x int) {
+	address, _ := t.reference(p)
+	if t.recording() {
+		t.step.Writes = append(t.step.Writes, Access{Address: address, Value: x, Previous: t.data.read(address)})
+	}
+	t.data.write(address, x)
+	t.modified(address)
+}
 
-	return params
+// First returns the value at the first index, aka the output.
+// This return value is invalid if the tape has not been run
+func (t Tape) First() int {
+	return t.data.read(0)
 }
 
-// Value returns the value/opcode at the cursor
-func (t Tape) Value() int {
-	return t.data[t.cursor]
+// MemoryUsage returns the number of memory cells currently allocated by the tape
+func (t Tape) MemoryUsage() int {
+	return t.data.usage()
 }
 
-// Resolve returns the address to a value. This value may be used as a source or destination
-func (t *Tape) Resolve(p param) *int {
-	switch p.mode {
-	case positionMode:
-		return &t.data[p.value]
-	case immediateMode:
-		return &p.value
-	case relativeMode:
-		return &t.data[p.value+t.relativeBase]
-	default:
-		log.Fatal("Invalid mode:", p.mode)
-		return nil // this will never be hit
+// PeakMemoryUsage returns the largest number of memory cells the tape has had allocated at once
+func (t Tape) PeakMemoryUsage() int {
+	return t.data.peakUsage()
+}
+
+// Cursor returns the address of the next instruction to run
+func (t Tape) Cursor() int {
+	return t.cursor
+}
+
+// RelativeBase returns the base address used by relative mode params
+func (t Tape) RelativeBase() int {
+	return t.relativeBase
+}
+
+// Peek returns the value at an address in the tape's memory. Values too large for an int
+// fail with ErrOverflow, and can be read with PeekBig instead.
+func (t Tape) Peek(address int) (int, error) {
+	if x, ok := t.bigs[address]; ok {
+		return 0, fmt.Errorf("intcode: %w: %s at %d", ErrOverflow, x, address)
 	}
+	return t.data.Read(address)
 }
 
-// First returns the value at the first index, aka the output.
-// This return value is invalid if the tape has not been run
-func (t Tape) First() int {
-	return t.data[0]
+// queueContents returns the values in a queue, without removing them
+func queueContents(q intqueue.Queue) []int {
+	var values []int
+	for !q.Empty() {
+		values = append(values, q.Pop())
+	}
+	return values
+}
+
+// PendingInput returns the queued input which has not been read yet
+func (t Tape) PendingInput() []int {
+	return queueContents(t.input)
+}
+
+// PendingOutput returns the output which has not been taken off the tape yet
+func (t Tape) PendingOutput() []int {
+	return queueContents(t.output)
 }
 
 func (t *Tape) Input(x int) {
@@ -120,157 +243,223 @@ func (t Tape) Output() intqueue.Queue {
 	return t.output
 }
 
-func (t *Tape) RunNextInstruction() {
-	value := t.Value()
-	opcode := decodeOpcode(value)
+// RunNextInstruction runs the instruction at the cursor. If the instruction fails, an *Error is returned
+// and the cursor is left on the failed instruction, so it can be retried once the problem is fixed
+// (e.g. by queueing more input).
+func (t *Tape) RunNextInstruction() error {
+	start := t.cursor
+	if err := t.runInstruction(); err != nil {
+		t.cursor = start
+		return t.fault(err, start)
+	}
+	t.instructions++
+	return nil
+}
 
-	// fmt.Println("Processing value:", value)
-	// fmt.Println("Current data:", t.data)
-	// fmt.Println("Opcode:", opcode)
+func (t *Tape) runInstruction() error {
+	if err := checkAddress(t.cursor); err != nil {
+		return err
+	}
 
-	switch opcode {
-	case addOpcode:
-		{
-			params := t.GetParams(value, 3)
-			a := t.Resolve(params[0])
-			b := t.Resolve(params[1])
-			dest := t.Resolve(params[2])
-			*dest = *a + *b
+	value := t.data.read(t.cursor)
+	d, err := t.decode(t.cursor, value)
+	if err != nil {
+		return err
+	}
+	opcode := int(d.opcode)
+	if opcode == haltOpcode {
+		return fmt.Errorf("%w: %d", ErrInvalidOpcode, opcode)
+	}
+
+	if t.recording() {
+		t.beginStep(value, opcode)
+	}
+
+	// params live in a fixed size array rather than a slice, so running an instruction doesn't allocate
+	var params [3]param
+	paramCount := int(d.paramCount)
+	for i := 0; i < paramCount; i++ {
+		// An instruction at the very top of memory has params past the last address
+		address := t.cursor + 1 + i
+		if err := checkAddress(address); err != nil {
+			return err
 		}
-	case multiplyOpcode:
+		params[i] = param{t.data.read(address), int(d.modes[i])}
+	}
+	t.cursor += paramCount + 1
+
+	p, err := t.resolveAll(params[:paramCount], int(d.destination))
+	if err != nil {
+		return err
+	}
+
+	// Cells holding values too large for an int only exist in BigWords mode
+	var wide [3]*big.Int
+	if t.bigs != nil {
+		if wide, err = t.bigOperands(opcode, params[:paramCount], int(d.destination)); err != nil {
+			return err
+		}
+	}
+
+	switch opcode {
+	case addOpcode, multiplyOpcode, lessThanOpcode, equalsOpcode:
 		{
-			params := t.GetParams(value, 3)
-			a := t.Resolve(params[0])
-			b := t.Resolve(params[1])
-			dest := t.Resolve(params[2])
-			*dest = (*a) * (*b)
+			if err := t.arithmetic(opcode, params[2], p[0], p[1], wide[0], wide[1]); err != nil {
+				return err
+			}
 		}
 	case inputOpcode:
 		{
-			params := t.GetParams(value, 1)
-			dest := t.Resolve(params[0])
-			*dest = t.input.Pop()
+			// Everything that can fail has been checked by now, so an input is never read and then lost
+			x, ok := t.readInput()
+			t.waiting = !ok
+			if !ok {
+				if t.recording() {
+					t.notifyWait()
+				}
+				return ErrInputExhausted
+			}
+			t.store(params[0], x)
 		}
 	case outputOpcode:
 		{
-			params := t.GetParams(value, 1)
-			value := t.Resolve(params[0])
-			t.output.Push(*value)
-		}
-	case jumpIfTrueOpcode:
-		{
-			params := t.GetParams(value, 2)
-			testValue := t.Resolve(params[0])
-			jumpIndex := t.Resolve(params[1])
-			if *testValue != 0 {
-				t.cursor = *jumpIndex
+			if wide[0] != nil {
+				err = t.writeBigOutput(wide[0])
+			} else {
+				err = t.writeOutput(p[0])
 			}
-		}
-	case jumpIfFalseOpcode:
-		{
-			params := t.GetParams(value, 2)
-			testValue := t.Resolve(params[0])
-			jumpIndex := t.Resolve(params[1])
-			if *testValue == 0 {
-				t.cursor = *jumpIndex
+			if err != nil {
+				return err
 			}
 		}
-	case lessThanOpcode:
+	case jumpIfTrueOpcode:
 		{
-			params := t.GetParams(value, 3)
-			a := t.Resolve(params[0])
-			b := t.Resolve(params[1])
-			destination := t.Resolve(params[2])
-
-			writeValue := 0
-			if *a < *b {
-				writeValue = 1
+			testValue, jumpIndex := p[0], p[1]
+			if testValue != 0 || wide[0] != nil {
+				t.cursor = jumpIndex
 			}
-
-			*destination = writeValue
 		}
-	case equalsOpcode:
+	case jumpIfFalseOpcode:
 		{
-			params := t.GetParams(value, 3)
-			a := t.Resolve(params[0])
-			b := t.Resolve(params[1])
-			destination := t.Resolve(params[2])
-
-			writeValue := 0
-			if *a == *b {
-				writeValue = 1
+			testValue, jumpIndex := p[0], p[1]
+			if testValue == 0 && wide[0] == nil {
+				t.cursor = jumpIndex
 			}
-			*destination = writeValue
 		}
 	case relativeAdjustOpcode:
 		{
-			params := t.GetParams(value, 1)
-			increment := t.Resolve(params[0])
-			t.relativeBase += *increment
+			t.relativeBase += p[0]
 		}
-	default:
-		log.Fatal("Invalid opcode: ", opcode)
 	}
-}
 
-// Run will run the tape from the current data/cursor until it halts or hits an unknown opcode
-func (t *Tape) RunUntilHalt() {
-	for !t.IsHalted() {
-		t.RunNextInstruction()
+	if t.recording() {
+		t.endStep()
 	}
+
+	return nil
 }
 
-func (t *Tape) RunUntilNextOutput() int {
-	for !t.IsHalted() && t.output.Empty() {
-		t.RunNextInstruction()
-	}
+// Run will run the tape from the current data/cursor until it halts or hits an error.
+// If the tape has an instruction limit, a *LimitError is returned once it is reached.
+func (t *Tape) RunUntilHalt() error {
+	return t.RunContext(context.Background())
+}
 
-	if t.output.Empty() {
-		return 0
+// RunUntilNextOutput runs the tape until it outputs a value, and returns that value.
+// If the tape halts without outputting anything, ErrHalted is returned.
+// When an Output is connected, the value is returned as well as being written to it.
+func (t *Tape) RunUntilNextOutput() (int, error) {
+	status, value, err := t.Run(0)
+	switch status {
+	case Halted:
+		return 0, ErrHalted
+	case NeedsInput:
+		return 0, t.fault(ErrInputExhausted, t.cursor)
+	default:
+		return value, err
 	}
-
-	return t.output.Pop()
 }
 
-func (t Tape) Set(i int, x int) {
-	t.data[i] = x
+// Set writes x to address i, growing the tape's memory if needed
+func (t Tape) Set(i int, x int) error {
+	if err := t.data.Write(i, x); err != nil {
+		return err
+	}
+	t.modified(i)
+	return nil
 }
 
 func (t *Tape) ClearInput() {
 	t.input.Clear()
 }
 
+func (t *Tape) ClearOutput() {
+	t.output.Clear()
+}
+
 // GetTapeData returns data for a tape from the given path
-func GetTapeData(path string) []int {
+func GetTapeData(path string) ([]int, error) {
 	file := datafile.Open(path)
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	data, err := ReadTapeData(file)
+	if err != nil {
+		return nil, fmt.Errorf("intcode: %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// ReadTapeData reads comma-separated tape data from the first line of r
+func ReadTapeData(r io.Reader) ([]int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
 	scanner.Scan()
-	line := scanner.Text()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	line := strings.TrimSpace(scanner.Text())
 
 	numberStrings := strings.Split(line, ",")
-	data := make([]int, len(numberStrings)*8)
+	data := make([]int, len(numberStrings))
 	for i, numberString := range numberStrings {
-		number, err := strconv.Atoi(numberString)
+		number, err := strconv.Atoi(strings.TrimSpace(numberString))
 		if err != nil {
-			log.Fatal(err)
+			return nil, fmt.Errorf("value %d: %w", i, err)
 		}
 		data[i] = int(number)
 	}
 
-	return data
+	return data, nil
+}
+
+// ParseValues parses comma-separated values, such as input or addresses given to a command.
+// Spaces around values and empty fields are ignored, so an empty string gives no values.
+func ParseValues(s string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("not a number: %s", field)
+		}
+		values = append(values, value)
+	}
+	return values, nil
 }
 
 // CreateBlankTape returns a blank tape based on the given input
-func CreateBlankTape(path string) Tape {
-	data := GetTapeData(path)
-	return Tape{data, 0, intqueue.Queue{}, intqueue.Queue{}, 0}
+func CreateBlankTape(path string) (Tape, error) {
+	data, err := GetTapeData(path)
+	if err != nil {
+		return Tape{}, err
+	}
+	return CreateTapeCopy(data), nil
 }
 
 // CreateTapeCopy creates a new tape with the given data copied
 func CreateTapeCopy(data []int) Tape {
-	dataCopy := make([]int, len(data))
-	copy(dataCopy, data)
-	return Tape{dataCopy, 0, intqueue.Queue{}, intqueue.Queue{}, 0}
+	return Tape{data: newMemory(data)}
 }
@@ -0,0 +1,73 @@
+package intcode
+
+import "context"
+
+// contextCheckInterval is roughly how many instructions RunContext runs between checks of its context
+const contextCheckInterval = 1024
+
+// SetInstructionLimit stops the tape once it has run limit instructions in total, counting
+// from when it was created, or 0 for no limit. Runs then fail with a *LimitError wrapping
+// ErrStepLimit, and Run returns StepLimitReached along with it.
+func (t *Tape) SetInstructionLimit(limit int) {
+	t.maxInstructions = limit
+}
+
+// InstructionLimit returns the most instructions the tape may run, or 0 if there is no limit
+func (t Tape) InstructionLimit() int {
+	return t.maxInstructions
+}
+
+// InstructionCount returns how many instructions the tape has run
+func (t Tape) InstructionCount() int {
+	return t.instructions
+}
+
+// limitReached returns a *LimitError if the tape has run all the instructions it may
+func (t *Tape) limitReached() error {
+	if t.maxInstructions > 0 && t.instructions >= t.maxInstructions {
+		return &LimitError{Err: ErrStepLimit, Cursor: t.cursor, Instructions: t.instructions}
+	}
+	return nil
+}
+
+// blockLimit returns the most instructions a compiled block may run, given a limit for the run
+// (0 for none) and the tape's own limit
+func (t *Tape) blockLimit(limit int) int {
+	if t.maxInstructions > 0 {
+		if remaining := t.maxInstructions - t.instructions; limit == 0 || remaining < limit {
+			return remaining
+		}
+	}
+	return limit
+}
+
+// RunContext runs the tape until it halts or hits an error, like RunUntilHalt, but gives up once
+// ctx is done, returning a *LimitError which wraps the context's error. This means runaway tapes
+// can be abandoned with a timeout or cancellation. The context is only checked between
+// instructions, so a tape blocked reading from a connected Input waits for the input regardless.
+func (t *Tape) RunContext(ctx context.Context) error {
+	done := ctx.Done()
+	check := t.instructions
+	for !t.halted() {
+		if done != nil && t.instructions >= check {
+			select {
+			case <-done:
+				return &LimitError{Err: ctx.Err(), Cursor: t.cursor, Instructions: t.instructions}
+			default:
+			}
+			check = t.instructions + contextCheckInterval
+		}
+		if err := t.limitReached(); err != nil {
+			return err
+		}
+
+		if t.runBlock(t.blockLimit(contextCheckInterval)) > 0 {
+			continue
+		}
+		if err := t.RunNextInstruction(); err != nil {
+			return err
+		}
+	}
+	t.notifyHalt()
+	return nil
+}
@@ -0,0 +1,227 @@
+package intcode
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+const (
+	pageBits = 6
+	pageSize = 1 << pageBits
+	// densePages is how many pages are tracked in a slice before falling back to a map,
+	// so the common case of small addresses avoids hashing on every access
+	densePages = 1 << 16
+)
+
+type page struct {
+	cells [pageSize]int
+	// owner is the token of the memory allowed to write to the page in place.
+	// Any other memory sharing the page must copy it first. It isn't a pointer, so
+	// that the garbage collector doesn't have to scan pages.
+	owner uint64
+}
+
+// memory is a sparse address space. Pages are only allocated when written to, untouched
+// cells read as zero, and negative addresses are rejected. Copies share pages until one
+// of them writes to a page, at which point the writer gets its own copy of that page.
+type memory struct {
+	dense  []*page
+	sparse map[int]*page
+	// shared is set while dense and sparse belong to another memory too, so they are copied
+	// before a page is added to them
+	shared bool
+	// token is given out when the memory first writes to a page, and is 0 until then
+	token     uint64
+	pageCount int
+	peakPages int
+}
+
+func newMemory(data []int) *memory {
+	m := &memory{}
+	for i, x := range data {
+		if x != 0 {
+			m.write(i, x)
+		}
+	}
+	return m
+}
+
+func checkAddress(address int) error {
+	if address < 0 {
+		return fmt.Errorf("%w: %d", ErrOutOfBounds, address)
+	}
+	return nil
+}
+
+// lookup returns the page holding address, or nil if it has never been written. Negative
+// addresses have no page.
+func (m *memory) lookup(address int) *page {
+	if address < 0 {
+		return nil
+	}
+	index := address >> pageBits
+	if index < len(m.dense) {
+		return m.dense[index]
+	}
+	return m.sparse[index]
+}
+
+// setPage stores p as the page holding address
+func (m *memory) setPage(address int, p *page) {
+	if m.shared {
+		m.dense = append([]*page(nil), m.dense...)
+		if m.sparse != nil {
+			sparse := make(map[int]*page, len(m.sparse))
+			for i, q := range m.sparse {
+				sparse[i] = q
+			}
+			m.sparse = sparse
+		}
+		m.shared = false
+	}
+	index := address >> pageBits
+	if index < densePages {
+		if index >= len(m.dense) {
+			dense := make([]*page, index+1)
+			copy(dense, m.dense)
+			m.dense = dense
+		}
+		m.dense[index] = p
+	} else {
+		if m.sparse == nil {
+			m.sparse = map[int]*page{}
+		}
+		m.sparse[index] = p
+	}
+}
+
+// writable returns the page holding address, creating it or copying it from a shared page if needed
+func (m *memory) writable(address int) *page {
+	if m.token == 0 {
+		m.token = newToken()
+	}
+	p := m.lookup(address)
+	if p != nil && p.owner == m.token {
+		return p
+	}
+
+	if p == nil {
+		p = &page{}
+		m.pageCount++
+		if m.pageCount > m.peakPages {
+			m.peakPages = m.pageCount
+		}
+	} else {
+		pageCopy := *p
+		p = &pageCopy
+	}
+	p.owner = m.token
+	m.setPage(address, p)
+	return p
+}
+
+// read returns the value at a non-negative address
+func (m *memory) read(address int) int {
+	p := m.lookup(address)
+	if p == nil {
+		return 0
+	}
+	return p.cells[address&(pageSize-1)]
+}
+
+// write sets the value at a non-negative address
+func (m *memory) write(address int, x int) {
+	p := m.lookup(address)
+	if p == nil && x == 0 {
+		return
+	}
+	if p == nil || p.owner != m.token {
+		p = m.writable(address)
+	}
+	p.cells[address&(pageSize-1)] = x
+}
+
+// frozen is the owner of pages belonging to memory which is never written to, such as a snapshot's
+// or a program's image. No memory holds it as its token, so any memory sharing those pages copies
+// them before writing.
+var frozen = newToken()
+
+// tokens is the last token given out by newToken
+var tokens atomic.Uint64
+
+// newToken returns a token no other memory has, which is never 0
+func newToken() uint64 {
+	return tokens.Add(1)
+}
+
+// clone returns a copy of the memory which shares all of its pages, and the tables of them until
+// it adds a page of its own. The copy copies a page before writing to it. m is not changed, so a
+// frozen memory can be cloned from several goroutines at once.
+func (m *memory) clone() *memory {
+	return &memory{dense: m.dense, sparse: m.sparse, shared: true, pageCount: m.pageCount, peakPages: m.peakPages}
+}
+
+// freeze returns a copy of the memory which is never written to, sharing all of its pages. m gives
+// up ownership of its pages and tables, so that it copies them before changing them too.
+func (m *memory) freeze() *memory {
+	c := m.clone()
+	c.token = frozen
+	m.token = 0
+	m.shared = true
+	return c
+}
+
+// equal returns whether two memories hold the same values, however their pages are allocated or shared
+func (m *memory) equal(other *memory) bool {
+	return m.within(other) && other.within(m)
+}
+
+// within returns whether every page m has allocated holds the same values in other
+func (m *memory) within(other *memory) bool {
+	var zero page
+	same := func(index int, p *page) bool {
+		q := other.lookup(index << pageBits)
+		if q == nil {
+			q = &zero
+		}
+		return p.cells == q.cells
+	}
+	for index, p := range m.dense {
+		if p != nil && !same(index, p) {
+			return false
+		}
+	}
+	for index, p := range m.sparse {
+		if !same(index, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// usage returns the number of cells currently allocated
+func (m *memory) usage() int {
+	return m.pageCount * pageSize
+}
+
+// peakUsage returns the largest number of cells that have been allocated at once
+func (m *memory) peakUsage() int {
+	return m.peakPages * pageSize
+}
+
+// Read returns the value at address
+func (m *memory) Read(address int) (int, error) {
+	if err := checkAddress(address); err != nil {
+		return 0, err
+	}
+	return m.read(address), nil
+}
+
+// Write sets the value at address, growing the memory if needed
+func (m *memory) Write(address int, x int) error {
+	if err := checkAddress(address); err != nil {
+		return err
+	}
+	m.write(address, x)
+	return nil
+}
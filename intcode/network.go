@@ -0,0 +1,340 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Scheduler chooses how a Network runs its machines
+type Scheduler int
+
+const (
+	// RoundRobin runs the machines one at a time on the calling goroutine, in the order they were
+	// added, each until it halts or waits for input. Runs are deterministic.
+	RoundRobin Scheduler = iota
+	// Concurrent runs each machine on its own goroutine
+	Concurrent
+)
+
+// machine is a tape in a network, with the values waiting to be read by it
+type machine struct {
+	tape  *Tape
+	inbox []int
+	links []int
+	// outputs holds everything the machine has output
+	outputs []int
+	// sink, if set, receives the machine's output instead of its links
+	sink Output
+	// polls is set if the machine reads idleInput when its inbox is empty, and emptyPolls counts
+	// how many times in a row it has done so
+	polls      bool
+	idleInput  int
+	emptyPolls int
+	// waiting is set while the machine is blocked on an empty inbox, in the Concurrent scheduler
+	waiting bool
+	done    bool
+}
+
+// Network runs a set of tapes whose output is sent to each other's input, such as a chain of
+// amplifiers with a feedback loop. Machines are added with Add and connected with Link, then the
+// whole network is run with Run until every machine halts, or the network goes idle: every machine
+// still running is waiting for input which none of the others will ever send.
+type Network struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	machines []*machine
+	idle     func() bool
+	// activity counts values output, and delivered to and read from inboxes, so the schedulers
+	// can tell whether anything happened
+	activity int
+	// waiting and running count the machines blocked on an empty inbox and the machines which
+	// haven't stopped, in the Concurrent scheduler. Once stopped is set, waiting machines give up.
+	waiting int
+	running int
+	stopped bool
+}
+
+// NewNetwork creates an empty network
+func NewNetwork() *Network {
+	n := &Network{}
+	n.cond = sync.NewCond(&n.mu)
+	return n
+}
+
+// Add adds a tape to the network, and returns the index used to refer to it. The tape's input
+// and output are connected to the network, replacing anything connected already, but values
+// queued on the tape with Input are still read first.
+func (n *Network) Add(t *Tape) int {
+	m := &machine{tape: t}
+	n.machines = append(n.machines, m)
+	t.ConnectInput(InputFunc(func() (int, bool) {
+		return n.read(m)
+	}))
+	t.ConnectOutput(OutputFunc(func(x int) error {
+		return n.write(m, x)
+	}))
+	return len(n.machines) - 1
+}
+
+// Tape returns the tape added to the network at index i
+func (n *Network) Tape(i int) *Tape {
+	return n.machines[i].tape
+}
+
+// Len returns how many machines are in the network
+func (n *Network) Len() int {
+	return len(n.machines)
+}
+
+// Link sends everything machine from outputs to machine to. A machine can be linked to several
+// others, in which case each of them gets every value.
+func (n *Network) Link(from int, to int) {
+	n.machines[from].links = append(n.machines[from].links, to)
+}
+
+// SetOutput sends everything machine i outputs to o, instead of to the machines it is linked to.
+// o can send values on with Send, including while the network is running.
+func (n *Network) SetOutput(i int, o Output) {
+	n.machines[i].sink = o
+}
+
+// SetIdleInput makes machine i read x when its inbox is empty, rather than waiting for a value.
+// The machine is still considered to be waiting if it reads x twice in a row, so a network of
+// polling machines can go idle.
+func (n *Network) SetIdleInput(i int, x int) {
+	n.machines[i].polls = true
+	n.machines[i].idleInput = x
+}
+
+// OnIdle sets a function to call when the network goes idle. It can send values to wake the
+// network up again, and returns whether to carry on running. Without one, or if it sends nothing,
+// an idle network with machines still running stops with ErrDeadlock.
+func (n *Network) OnIdle(f func() bool) {
+	n.idle = f
+}
+
+// Send queues values for machine to to read. It is safe to call while the network is running.
+func (n *Network) Send(to int, values ...int) {
+	n.mu.Lock()
+	n.deliver(n.machines[to], values...)
+	n.mu.Unlock()
+}
+
+// Outputs returns every value machine i has output
+func (n *Network) Outputs(i int) []int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]int(nil), n.machines[i].outputs...)
+}
+
+// deliver adds values to a machine's inbox, waking it if it is waiting. n.mu must be held.
+func (n *Network) deliver(m *machine, values ...int) {
+	m.inbox = append(m.inbox, values...)
+	n.activity += len(values)
+	if m.waiting && len(values) > 0 {
+		// The waiting count is updated here rather than by the machine once it wakes, so the
+		// network is never seen as idle in between
+		m.waiting = false
+		n.waiting--
+		n.cond.Broadcast()
+	}
+}
+
+// read is the input function for a machine. In the RoundRobin scheduler, an empty inbox makes
+// the tape pause so the next machine can run, and in the Concurrent one it blocks.
+func (n *Network) read(m *machine) (int, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for {
+		// Once the network has stopped, nothing more is read, so the machines stop where they are
+		if n.stopped {
+			return 0, false
+		}
+		if len(m.inbox) > 0 {
+			x := m.inbox[0]
+			m.inbox = m.inbox[1:]
+			m.emptyPolls = 0
+			n.activity++
+			return x, true
+		}
+		if m.polls && m.emptyPolls == 0 {
+			m.emptyPolls++
+			return m.idleInput, true
+		}
+		// Only the Concurrent scheduler counts running machines
+		if n.running == 0 {
+			return 0, false
+		}
+
+		m.waiting = true
+		n.waiting++
+		n.cond.Broadcast()
+		for m.waiting && !n.stopped {
+			n.cond.Wait()
+		}
+	}
+}
+
+// write is the output function for a machine
+func (n *Network) write(m *machine, x int) error {
+	n.mu.Lock()
+	m.outputs = append(m.outputs, x)
+	n.activity++
+	sink := m.sink
+	if sink == nil {
+		for _, to := range m.links {
+			n.deliver(n.machines[to], x)
+		}
+	}
+	n.mu.Unlock()
+
+	if sink != nil {
+		return sink.Write(x)
+	}
+	return nil
+}
+
+// wake calls the idle function, if there is one, and returns whether the network should carry
+// on, and whether it is deadlocked. n.mu must not be held.
+func (n *Network) wake() (more bool, deadlocked bool) {
+	if n.idle == nil {
+		return false, true
+	}
+	n.mu.Lock()
+	activity := n.activity
+	n.mu.Unlock()
+
+	if !n.idle() {
+		return false, false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.activity == activity {
+		return false, true
+	}
+	return true, false
+}
+
+// Run runs the network until every machine halts, the idle function says to stop, or a machine
+// fails. A machine failing stops the network with its error. If the network goes idle while
+// machines are still running, and nothing wakes it up, ErrDeadlock is returned.
+func (n *Network) Run(scheduler Scheduler) error {
+	n.mu.Lock()
+	n.stopped = false
+	n.mu.Unlock()
+
+	if scheduler == Concurrent {
+		return n.runConcurrently()
+	}
+	return n.runInTurn()
+}
+
+func (n *Network) runInTurn() error {
+	for {
+		n.mu.Lock()
+		activity := n.activity
+		n.mu.Unlock()
+
+		running := 0
+		for i, m := range n.machines {
+			if m.done {
+				continue
+			}
+			m.emptyPolls = 0
+			err := m.tape.RunUntilHalt()
+			if err == nil {
+				m.done = true
+				continue
+			}
+			if !m.tape.WaitingForInput() {
+				return fmt.Errorf("machine %d: %w", i, err)
+			}
+			running++
+		}
+		if running == 0 {
+			return nil
+		}
+
+		n.mu.Lock()
+		idle := n.activity == activity
+		n.mu.Unlock()
+		if !idle {
+			continue
+		}
+		more, deadlocked := n.wake()
+		if deadlocked {
+			return ErrDeadlock
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+func (n *Network) runConcurrently() error {
+	errs := make([]error, len(n.machines))
+	var wg sync.WaitGroup
+
+	n.mu.Lock()
+	for i, m := range n.machines {
+		if m.done {
+			continue
+		}
+		n.running++
+		wg.Add(1)
+		go func(i int, m *machine) {
+			defer wg.Done()
+			err := m.tape.RunUntilHalt()
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if err == nil {
+				m.done = true
+			} else if !n.stopped || !errors.Is(err, ErrInputExhausted) {
+				errs[i] = fmt.Errorf("machine %d: %w", i, err)
+				n.stopped = true
+			}
+			n.running--
+			n.cond.Broadcast()
+		}(i, m)
+	}
+
+	// Watch for the network going idle, which is when every machine still running is waiting
+	deadlocked := false
+	for {
+		for n.running > 0 && n.waiting < n.running && !n.stopped {
+			n.cond.Wait()
+		}
+		if n.running == 0 || n.stopped {
+			break
+		}
+
+		n.mu.Unlock()
+		more, stuck := n.wake()
+		n.mu.Lock()
+		if !more {
+			deadlocked = stuck
+			n.stopped = true
+			n.cond.Broadcast()
+			break
+		}
+	}
+	n.mu.Unlock()
+	wg.Wait()
+
+	n.mu.Lock()
+	n.running, n.waiting = 0, 0
+	n.mu.Unlock()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	if deadlocked {
+		return ErrDeadlock
+	}
+	return nil
+}
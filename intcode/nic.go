@@ -0,0 +1,219 @@
+package intcode
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MonitorAddress is the address which packets are sent to for a PacketNetwork's monitor
+const MonitorAddress = 255
+
+// idlePoll is what a NIC reads when no packet is waiting for it
+const idlePoll = -1
+
+// Packet is a pair of values sent by a machine in a PacketNetwork to an address
+type Packet struct {
+	From, To int
+	X, Y     int
+}
+
+func (p Packet) String() string {
+	return fmt.Sprintf("%d -> %d: %d, %d", p.From, p.To, p.X, p.Y)
+}
+
+// Router decides where the packets sent in a PacketNetwork go. Route is called once for each
+// packet, and can deliver it (or others) with Deliver, change it, or drop it. An error fails the
+// machine which sent the packet.
+type Router interface {
+	Route(n *PacketNetwork, p Packet) error
+}
+
+// RouterFunc adapts a function to a Router
+type RouterFunc func(n *PacketNetwork, p Packet) error
+
+func (f RouterFunc) Route(n *PacketNetwork, p Packet) error {
+	return f(n, p)
+}
+
+// DirectRouter delivers every packet to its destination address
+var DirectRouter Router = RouterFunc(func(n *PacketNetwork, p Packet) error {
+	return n.Deliver(p)
+})
+
+// Monitor is the component at MonitorAddress, such as a NAT
+type Monitor interface {
+	// Receive is called with each packet delivered to MonitorAddress
+	Receive(p Packet)
+	// Idle is called when every machine is waiting for a packet. It can send packets to wake
+	// the network up, and returns whether to carry on running.
+	Idle(n *PacketNetwork) (bool, error)
+}
+
+// PacketNetwork runs machines with network interface controllers: each one is given its address
+// as its first input, sends packets as three outputs (the destination address, then X and Y),
+// and reads packets as X then Y, or -1 if none is waiting. The network runs until every machine
+// halts or it goes idle, when its monitor, if it has one, decides whether to wake it up.
+type PacketNetwork struct {
+	network *Network
+	// mu is held while a packet is logged and routed, so routers, monitors and the logging
+	// function are never called from more than one machine at a time
+	mu      sync.Mutex
+	router  Router
+	monitor Monitor
+	log     func(p Packet)
+	sent    int
+	err     error
+}
+
+// NewPacketNetwork creates a network with no machines, which routes packets with DirectRouter
+func NewPacketNetwork() *PacketNetwork {
+	n := &PacketNetwork{network: NewNetwork(), router: DirectRouter}
+	n.network.OnIdle(n.idle)
+	return n
+}
+
+// Add adds a tape to the network, and returns its address. Machines are given addresses from 0
+// in the order they are added.
+func (n *PacketNetwork) Add(t *Tape) int {
+	address := n.network.Add(t)
+	n.network.Send(address, address)
+	n.network.SetIdleInput(address, idlePoll)
+
+	// Only the machine's own goroutine writes its output, so pending needs no locking
+	var pending []int
+	n.network.SetOutput(address, OutputFunc(func(x int) error {
+		pending = append(pending, x)
+		if len(pending) < 3 {
+			return nil
+		}
+		p := Packet{From: address, To: pending[0], X: pending[1], Y: pending[2]}
+		pending = pending[:0]
+		return n.Send(p)
+	}))
+	return address
+}
+
+// Tape returns the tape of the machine at address
+func (n *PacketNetwork) Tape(address int) *Tape {
+	return n.network.Tape(address)
+}
+
+// Len returns how many machines are in the network
+func (n *PacketNetwork) Len() int {
+	return n.network.Len()
+}
+
+// SetRouter replaces the network's router
+func (n *PacketNetwork) SetRouter(r Router) {
+	n.router = r
+}
+
+// SetMonitor sets the component which receives the packets sent to MonitorAddress, and decides
+// what happens when the network goes idle. Without one, going idle ends the run.
+func (n *PacketNetwork) SetMonitor(m Monitor) {
+	n.monitor = m
+}
+
+// OnPacket sets a function to call with every packet sent, before it is routed
+func (n *PacketNetwork) OnPacket(f func(p Packet)) {
+	n.log = f
+}
+
+// Sent returns how many packets have been sent
+func (n *PacketNetwork) Sent() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.sent
+}
+
+// Send logs a packet and passes it to the router, as if a machine had sent it. Monitors use it to
+// send packets from MonitorAddress.
+func (n *PacketNetwork) Send(p Packet) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent++
+	if n.log != nil {
+		n.log(p)
+	}
+	return n.router.Route(n, p)
+}
+
+// Deliver puts a packet in the queue of the machine it is addressed to, or hands it to the
+// monitor. It is for routers to call, and fails if there is nothing at the address.
+func (n *PacketNetwork) Deliver(p Packet) error {
+	if p.To == MonitorAddress && n.monitor != nil {
+		n.monitor.Receive(p)
+		return nil
+	}
+	if p.To < 0 || p.To >= n.network.Len() {
+		return fmt.Errorf("%w: packet %v", ErrNoRoute, p)
+	}
+	n.network.Send(p.To, p.X, p.Y)
+	return nil
+}
+
+// idle is the Network's idle function. Every machine is waiting, so no packets are being sent
+// while the monitor runs.
+func (n *PacketNetwork) idle() bool {
+	if n.monitor == nil {
+		return false
+	}
+	more, err := n.monitor.Idle(n)
+	if err != nil {
+		n.err = err
+		return false
+	}
+	return more
+}
+
+// Run runs the network with the given scheduler until every machine halts, it goes idle and the
+// monitor (if there is one) stops it, or a machine fails. If the monitor doesn't send anything to
+// wake the network up, ErrDeadlock is returned.
+func (n *PacketNetwork) Run(scheduler Scheduler) error {
+	n.err = nil
+	if err := n.network.Run(scheduler); err != nil {
+		return err
+	}
+	return n.err
+}
+
+// NAT is a Monitor which keeps the last packet sent to it, and sends it on to address 0 each time
+// the network goes idle. It stops the network once it has sent the same Y value twice in a row.
+type NAT struct {
+	last    Packet
+	hasLast bool
+	// Received and Sent hold every packet the NAT has received, and has sent to address 0
+	Received []Packet
+	Sent     []Packet
+}
+
+func (nat *NAT) Receive(p Packet) {
+	nat.Received = append(nat.Received, p)
+	nat.last = p
+	nat.hasLast = true
+}
+
+func (nat *NAT) Idle(n *PacketNetwork) (bool, error) {
+	if !nat.hasLast {
+		// Nothing will wake the network up
+		return true, nil
+	}
+
+	p := Packet{From: MonitorAddress, To: 0, X: nat.last.X, Y: nat.last.Y}
+	repeated := len(nat.Sent) > 0 && nat.Sent[len(nat.Sent)-1].Y == p.Y
+	if err := n.Send(p); err != nil {
+		return false, err
+	}
+	nat.Sent = append(nat.Sent, p)
+	return !repeated, nil
+}
+
+// Repeated returns the first Y value the NAT sent twice in a row, if it has
+func (nat *NAT) Repeated() (int, bool) {
+	for i := 1; i < len(nat.Sent); i++ {
+		if nat.Sent[i].Y == nat.Sent[i-1].Y {
+			return nat.Sent[i].Y, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,108 @@
+package intcode
+
+// Access is a single read or write of a memory cell by an instruction
+type Access struct {
+	Address int
+	Value   int
+	// Previous is the value that was overwritten, for writes
+	Previous int
+}
+
+// Step records everything a single executed instruction did
+type Step struct {
+	Cursor      int
+	Instruction int
+	Opcode      int
+	// Operands holds the resolved value of each param. For the param which is written to,
+	// it holds the address written to instead.
+	Operands         []int
+	Reads            []Access
+	Writes           []Access
+	RelativeBase     int
+	NextRelativeBase int
+	NextCursor       int
+}
+
+// Observer is notified after each instruction a tape runs. The step is reused between
+// instructions, so it must be copied if it is kept after Observe returns.
+type Observer interface {
+	Observe(t *Tape, step *Step)
+}
+
+// WaitObserver is an Observer which is also notified each time the tape stops on an input
+// instruction because no input is available
+type WaitObserver interface {
+	Observer
+	ObserveWait(t *Tape, cursor int)
+}
+
+// HaltObserver is an Observer which is also notified each time a run stops because the tape has
+// reached a halt instruction, which stops the tape rather than running like other instructions
+type HaltObserver interface {
+	Observer
+	ObserveHalt(t *Tape, cursor int)
+}
+
+// Attach adds an observer to be notified after each instruction the tape runs
+func (t *Tape) Attach(o Observer) {
+	t.observers = append(t.observers, o)
+}
+
+// Detach removes an observer added with Attach
+func (t *Tape) Detach(o Observer) {
+	for i, existing := range t.observers {
+		if existing == o {
+			t.observers = append(t.observers[:i:i], t.observers[i+1:]...)
+			return
+		}
+	}
+}
+
+// recording returns whether instructions need step records, for observers or the history
+func (t *Tape) recording() bool {
+	return len(t.observers) > 0 || t.history != nil
+}
+
+// beginStep resets the step record for the instruction at the cursor
+func (t *Tape) beginStep(value int, opcode int) {
+	t.step.Cursor = t.cursor
+	t.step.Instruction = value
+	t.step.Opcode = opcode
+	t.step.Operands = t.step.Operands[:0]
+	t.step.Reads = t.step.Reads[:0]
+	t.step.Writes = t.step.Writes[:0]
+	t.step.RelativeBase = t.relativeBase
+	if t.history != nil {
+		t.history.outputCount, t.history.lastOutput = t.outputCount, t.lastOutput
+	}
+}
+
+// endStep completes the step record, adds it to the history and notifies observers
+func (t *Tape) endStep() {
+	t.step.NextRelativeBase = t.relativeBase
+	t.step.NextCursor = t.cursor
+	if t.history != nil {
+		t.logStep()
+	}
+	for _, o := range t.observers {
+		o.Observe(t, &t.step)
+	}
+}
+
+// notifyWait tells any WaitObservers that the instruction at the cursor is waiting for input
+func (t *Tape) notifyWait() {
+	for _, o := range t.observers {
+		if w, ok := o.(WaitObserver); ok {
+			w.ObserveWait(t, t.step.Cursor)
+		}
+	}
+}
+
+// notifyHalt tells any HaltObservers that a run stopped on the halt instruction at the cursor
+func (t *Tape) notifyHalt() {
+	for _, o := range t.observers {
+		if h, ok := o.(HaltObserver); ok {
+			h.ObserveHalt(t, t.cursor)
+		}
+	}
+}
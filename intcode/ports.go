@@ -0,0 +1,171 @@
+package intcode
+
+import (
+	"bufio"
+	"fmt"
+	"intqueue"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Input supplies values to a tape's input instructions
+type Input interface {
+	// Read returns the next input value, or false if there is none available yet
+	Read() (int, bool)
+}
+
+// Output receives the values from a tape's output instructions
+type Output interface {
+	Write(x int) error
+}
+
+// InputFunc adapts a function to an Input
+type InputFunc func() (int, bool)
+
+func (f InputFunc) Read() (int, bool) {
+	return f()
+}
+
+// OutputFunc adapts a function to an Output
+type OutputFunc func(x int) error
+
+func (f OutputFunc) Write(x int) error {
+	return f(x)
+}
+
+// Queue is a first-in first-out queue of values which is both an Input and an Output,
+// so it can be used to connect the output of one tape to the input of another
+type Queue struct {
+	values intqueue.Queue
+}
+
+// Push adds a value to the back of the queue
+func (q *Queue) Push(x int) {
+	q.values.Push(x)
+}
+
+// Empty returns whether the queue has no values in it
+func (q *Queue) Empty() bool {
+	return q.values.Empty()
+}
+
+// Values returns the values in the queue, without removing them
+func (q *Queue) Values() []int {
+	return queueContents(q.values)
+}
+
+func (q *Queue) Read() (int, bool) {
+	if q.values.Empty() {
+		return 0, false
+	}
+	return q.values.Pop(), true
+}
+
+func (q *Queue) Write(x int) error {
+	q.values.Push(x)
+	return nil
+}
+
+// ConnectInput makes the tape read from source once the values queued with Input have been used.
+// Passing nil disconnects the current source.
+func (t *Tape) ConnectInput(source Input) {
+	t.source = source
+}
+
+// ConnectOutput makes the tape write its output to sink instead of queueing it.
+// Passing nil goes back to queueing output.
+func (t *Tape) ConnectOutput(sink Output) {
+	t.sink = sink
+}
+
+// WaitingForInput returns whether the tape is paused on an input instruction with no input available.
+// Running it again once input has been provided carries on from where it stopped.
+func (t Tape) WaitingForInput() bool {
+	return t.waiting
+}
+
+func (t *Tape) readInput() (int, bool) {
+	if !t.input.Empty() {
+		return t.input.Pop(), true
+	}
+	if t.source != nil {
+		return t.source.Read()
+	}
+	return 0, false
+}
+
+func (t *Tape) writeOutput(x int) error {
+	if t.sink != nil {
+		if err := t.sink.Write(x); err != nil {
+			return err
+		}
+	} else {
+		t.output.Push(x)
+	}
+	t.outputCount++
+	t.lastOutput = x
+	return nil
+}
+
+// ChanInput reads input from a channel, blocking until a value is sent.
+// Once the channel is closed, no more input is available.
+func ChanInput(ch <-chan int) Input {
+	return InputFunc(func() (int, bool) {
+		x, ok := <-ch
+		return x, ok
+	})
+}
+
+// ChanOutput sends output to a channel, blocking until it is received
+func ChanOutput(ch chan<- int) Output {
+	return OutputFunc(func(x int) error {
+		ch <- x
+		return nil
+	})
+}
+
+// ReaderInput reads input from r a byte at a time, as ASCII
+func ReaderInput(r io.Reader) Input {
+	br := bufio.NewReader(r)
+	return InputFunc(func() (int, bool) {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, false
+		}
+		return int(b), true
+	})
+}
+
+// PromptInput asks for each input value by writing prompt to w, and reads it from r as a number on
+// a line of its own, asking again if the line isn't a number. Once r runs out, no more input is available.
+func PromptInput(r io.Reader, w io.Writer, prompt string) Input {
+	scanner := bufio.NewScanner(r)
+	return InputFunc(func() (int, bool) {
+		for {
+			fmt.Fprint(w, prompt)
+			if !scanner.Scan() {
+				return 0, false
+			}
+			x, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+			if err == nil {
+				return x, true
+			}
+			fmt.Fprintln(w, "Invalid input")
+		}
+	})
+}
+
+// WriterOutput writes output to w as ASCII. Values outside of the ASCII range can't be
+// written as characters, so they are written as numbers on their own line instead.
+func WriterOutput(w io.Writer) Output {
+	return OutputFunc(func(x int) error {
+		var err error
+		if IsASCII(x) {
+			_, err = w.Write([]byte{byte(x)})
+		} else {
+			_, err = fmt.Fprintf(w, "%d\n", x)
+		}
+		return err
+	})
+}
@@ -0,0 +1,88 @@
+package intcode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestQueueConnection chains two echo tapes through a Queue
+func TestQueueConnection(t *testing.T) {
+	var between, out Queue
+	first, second := CreateTapeCopy(echo), CreateTapeCopy(echo)
+	first.Input(5)
+	first.ConnectOutput(&between)
+	second.ConnectInput(&between)
+	second.ConnectOutput(&out)
+
+	if err := second.RunUntilHalt(); !errors.Is(err, ErrInputExhausted) || !second.WaitingForInput() {
+		t.Fatalf("got %v before any input, expected the second tape to wait", err)
+	}
+	if err := first.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(between.Values(), []int{5}) || len(first.PendingOutput()) > 0 {
+		t.Fatalf("got %v in the queue and %v queued on the tape, expected [5] in the queue only", between.Values(), first.PendingOutput())
+	}
+	if err := second.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	if !between.Empty() || !reflect.DeepEqual(out.Values(), []int{5}) || second.WaitingForInput() {
+		t.Errorf("got %v left over and %v output, expected [5] passed through", between.Values(), out.Values())
+	}
+}
+
+// TestQueuedInputFirst checks that values queued with Input are read before a connected source
+func TestQueuedInputFirst(t *testing.T) {
+	tape := CreateTapeCopy(mustAssemble("in [0]\nin [1]\nout [0]\nout [1]\nhlt"))
+	tape.Input(1)
+	tape.ConnectInput(InputFunc(func() (int, bool) { return 2, true }))
+	if err := tape.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	if output := tape.PendingOutput(); !reflect.DeepEqual(output, []int{1, 2}) {
+		t.Errorf("got %v, expected [1 2]", output)
+	}
+}
+
+// TestChanPorts runs a tape on another goroutine, talking to it over channels
+func TestChanPorts(t *testing.T) {
+	in, out := make(chan int), make(chan int)
+	done := make(chan error)
+	tape := CreateTapeCopy(largeCompare)
+	tape.ConnectInput(ChanInput(in))
+	tape.ConnectOutput(ChanOutput(out))
+	go func() {
+		done <- tape.RunUntilHalt()
+	}()
+
+	in <- 9
+	if x := <-out; x != 1001 {
+		t.Errorf("got %d, expected 1001", x)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// A closed channel has no more input
+	close(in)
+	tape = CreateTapeCopy(echo)
+	tape.ConnectInput(ChanInput(in))
+	if err := tape.RunUntilHalt(); !errors.Is(err, ErrInputExhausted) {
+		t.Errorf("got %v reading from a closed channel, expected %v", err, ErrInputExhausted)
+	}
+}
+
+// TestOutputError checks that an error from an output stops the tape and is returned
+func TestOutputError(t *testing.T) {
+	failed := errors.New("output failed")
+	tape := CreateTapeCopy(echo)
+	tape.Input(1)
+	tape.ConnectOutput(OutputFunc(func(x int) error { return failed }))
+	if err := tape.RunUntilHalt(); !errors.Is(err, failed) {
+		t.Errorf("got %v, expected %v", err, failed)
+	}
+	if tape.IsHalted() {
+		t.Errorf("tape halted after its output failed")
+	}
+}
@@ -0,0 +1,186 @@
+package intcode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// protoBuffer encodes the handful of protocol buffer wire types needed for a pprof profile
+type protoBuffer struct {
+	bytes.Buffer
+}
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	b.WriteByte(byte(x))
+}
+
+// int64Field writes a varint field, skipping zero values as the format allows
+func (b *protoBuffer) int64Field(field int, x int64) {
+	if x == 0 {
+		return
+	}
+	b.varint(uint64(field) << 3)
+	b.varint(uint64(x))
+}
+
+func (b *protoBuffer) bytesField(field int, data []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(data)))
+	b.Write(data)
+}
+
+func (b *protoBuffer) packedField(field int, xs []int64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(uint64(x))
+	}
+	b.bytesField(field, packed.Bytes())
+}
+
+func (b *protoBuffer) message(field int, encode func(m *protoBuffer)) {
+	var m protoBuffer
+	encode(&m)
+	b.bytesField(field, m.Bytes())
+}
+
+// Field numbers from pprof's profile.proto
+const (
+	profileSampleType  = 1
+	profileSample      = 2
+	profileLocation    = 4
+	profileFunction    = 5
+	profileStringTable = 6
+	profilePeriodType  = 11
+	profilePeriod      = 12
+	profileDefaultType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID      = 1
+	locationAddress = 3
+	locationLine    = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// stringTable collects the strings used by a profile, which refers to them by index
+type stringTable struct {
+	strings []string
+	index   map[string]int64
+}
+
+func (s *stringTable) add(str string) int64 {
+	if s.index == nil {
+		s.index = map[string]int64{}
+	}
+	if i, ok := s.index[str]; ok {
+		return i
+	}
+	i := int64(len(s.strings))
+	s.strings = append(s.strings, str)
+	s.index[str] = i
+	return i
+}
+
+// WritePprof writes the profile in the gzipped protocol buffer format read by "go tool pprof".
+// Each executed instruction is a function named after its address and disassembly, called from
+// the loops it is part of, innermost first. Samples count instructions run, and the time before
+// each input and output instruction is recorded as a second sample type.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	var out protoBuffer
+	strs := stringTable{}
+	strs.add("")
+	filename := strs.add("intcode")
+
+	valueType := func(field int, kind string, unit string) {
+		out.message(field, func(m *protoBuffer) {
+			m.int64Field(valueTypeType, strs.add(kind))
+			m.int64Field(valueTypeUnit, strs.add(unit))
+		})
+	}
+	valueType(profileSampleType, "instructions", "count")
+	valueType(profileSampleType, "io_time", "nanoseconds")
+
+	// Every instruction and loop becomes a location with a function of its own
+	nextID := int64(1)
+	location := func(address int, name string) int64 {
+		id := nextID
+		nextID++
+		nameIndex := strs.add(name)
+		out.message(profileFunction, func(m *protoBuffer) {
+			m.int64Field(functionID, id)
+			m.int64Field(functionName, nameIndex)
+			m.int64Field(functionSystemName, nameIndex)
+			m.int64Field(functionFilename, filename)
+			m.int64Field(functionStartLine, int64(address))
+		})
+		out.message(profileLocation, func(m *protoBuffer) {
+			m.int64Field(locationID, id)
+			m.int64Field(locationAddress, int64(address))
+			m.message(locationLine, func(line *protoBuffer) {
+				line.int64Field(lineFunctionID, id)
+				line.int64Field(lineLine, int64(address))
+			})
+		})
+		return id
+	}
+
+	// Loops are sorted smallest first, so the innermost loop is the first one found for an address
+	loops := p.Loops()
+	sort.SliceStable(loops, func(i, j int) bool {
+		return loops[i].End-loops[i].Start < loops[j].End-loops[j].Start
+	})
+	loopIDs := make([]int64, len(loops))
+	for i, loop := range loops {
+		loopIDs[i] = location(loop.Start, fmt.Sprintf("loop %04d-%04d", loop.Start, loop.End))
+	}
+
+	for _, count := range p.Addresses() {
+		stack := []int64{location(count.Address, fmt.Sprintf("%04d %s", count.Address, count.Instruction))}
+		for i, loop := range loops {
+			if count.Address >= loop.Start && count.Address <= loop.End {
+				stack = append(stack, loopIDs[i])
+			}
+		}
+
+		var ioTime int64
+		if io, ok := p.io[count.Address]; ok {
+			ioTime = int64(io.Time)
+		}
+		out.message(profileSample, func(m *protoBuffer) {
+			m.packedField(sampleLocationID, stack)
+			m.packedField(sampleValue, []int64{int64(count.Count), ioTime})
+		})
+	}
+
+	valueType(profilePeriodType, "instructions", "count")
+	out.int64Field(profilePeriod, 1)
+	out.int64Field(profileDefaultType, strs.add("instructions"))
+	for _, str := range strs.strings {
+		out.bytesField(profileStringTable, []byte(str))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(out.Bytes()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
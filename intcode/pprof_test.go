@@ -0,0 +1,144 @@
+package intcode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// protoField is a field decoded from a protocol buffer message. Varints are in value, and
+// length-delimited fields in data.
+type protoField struct {
+	number int
+	value  uint64
+	data   []byte
+}
+
+// decodeProto splits a protocol buffer message into its fields. Only the wire types WritePprof
+// uses are understood.
+func decodeProto(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("bad field key")
+		}
+		b = b[n:]
+		field := protoField{number: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			if field.value, n = binary.Uvarint(b); n <= 0 {
+				return nil, fmt.Errorf("bad varint in field %d", field.number)
+			}
+			b = b[n:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return nil, fmt.Errorf("bad length in field %d", field.number)
+			}
+			field.data = b[n : n+int(length)]
+			b = b[n+int(length):]
+		default:
+			return nil, fmt.Errorf("unexpected wire type %d in field %d", key&7, field.number)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// decodePacked decodes a packed repeated varint field
+func decodePacked(b []byte) []uint64 {
+	var values []uint64
+	for len(b) > 0 {
+		x, n := binary.Uvarint(b)
+		if n <= 0 {
+			break
+		}
+		values = append(values, x)
+		b = b[n:]
+	}
+	return values
+}
+
+// TestWritePprof decodes a profile of countdown, and checks that it is well formed and that its
+// samples add up to the instructions run
+func TestWritePprof(t *testing.T) {
+	profiler := NewProfiler()
+	tape := CreateTapeCopy(countdown)
+	tape.Attach(profiler)
+	tape.Input(10)
+	if err := tape.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := profiler.WritePprof(&out); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := decodeProto(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stringTable []string
+	locations := map[uint64]bool{}
+	var samples [][]byte
+	for _, f := range fields {
+		switch f.number {
+		case profileStringTable:
+			stringTable = append(stringTable, string(f.data))
+		case profileLocation:
+			location, err := decodeProto(f.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			locations[location[0].value] = true
+		case profileSample:
+			samples = append(samples, f.data)
+		}
+	}
+	if len(stringTable) == 0 || stringTable[0] != "" {
+		t.Fatalf("string table %q doesn't start with the empty string", stringTable)
+	}
+
+	total := 0
+	for _, sample := range samples {
+		sampleFields, err := decodeProto(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range sampleFields {
+			switch f.number {
+			case sampleLocationID:
+				for _, id := range decodePacked(f.data) {
+					if !locations[id] {
+						t.Errorf("sample refers to missing location %d", id)
+					}
+				}
+			case sampleValue:
+				values := decodePacked(f.data)
+				if len(values) != 2 {
+					t.Fatalf("got %d sample values, expected one for each of the 2 sample types", len(values))
+				}
+				total += int(values[0])
+			}
+		}
+	}
+	if len(samples) != len(profiler.Addresses()) {
+		t.Errorf("got %d samples, expected one for each of the %d addresses run", len(samples), len(profiler.Addresses()))
+	}
+	if total != profiler.Instructions() {
+		t.Errorf("samples count %d instructions, expected %d", total, profiler.Instructions())
+	}
+}
@@ -0,0 +1,263 @@
+package intcode
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Profiler is an Observer which counts how often each instruction runs, finds hot loops, and
+// times the stretches of computation between input and output instructions
+type Profiler struct {
+	instructions int
+	addresses    map[int]*AddressCount
+	opcodes      map[int]int
+	// backJumps counts taken jumps to an earlier address, keyed by target and then jump address
+	backJumps map[[2]int]int
+	io        map[int]*IOCount
+	waits     map[int]int
+	// lastIO is when the last input or output instruction ran, and sinceIO is how many
+	// instructions have run since
+	lastIO  time.Time
+	sinceIO int
+}
+
+// AddressCount is how many times the instruction at an address ran
+type AddressCount struct {
+	Address     int
+	Count       int
+	Instruction Instruction
+}
+
+// OpcodeCount is how many instructions with an opcode ran
+type OpcodeCount struct {
+	Mnemonic string
+	Count    int
+}
+
+// Loop is a hot loop, found by a jump back to an earlier address
+type Loop struct {
+	// Start is the address jumped back to, and End is the address of the jump
+	Start, End int
+	// Iterations is how many times the jump was taken
+	Iterations int
+	// Instructions is how many instructions ran between Start and End, inclusive
+	Instructions int
+}
+
+// IOCount describes the computation leading up to an input or output instruction: how many
+// times it ran, and the instructions and time since the previous input or output
+type IOCount struct {
+	Address      int
+	Mnemonic     string
+	Events       int
+	Instructions int
+	Time         time.Duration
+	MaxTime      time.Duration
+}
+
+// InputWait is how many times the tape stopped on an input instruction with no input available
+type InputWait struct {
+	Address int
+	Count   int
+}
+
+// NewProfiler creates a profiler. Attach it to a tape to start profiling.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		addresses: map[int]*AddressCount{},
+		opcodes:   map[int]int{},
+		backJumps: map[[2]int]int{},
+		io:        map[int]*IOCount{},
+		waits:     map[int]int{},
+	}
+}
+
+// Observe counts a step
+func (p *Profiler) Observe(t *Tape, step *Step) {
+	if p.lastIO.IsZero() {
+		p.lastIO = time.Now()
+	}
+	p.instructions++
+	p.sinceIO++
+	p.opcodes[step.Opcode]++
+
+	count, ok := p.addresses[step.Cursor]
+	if !ok {
+		count = &AddressCount{Address: step.Cursor, Instruction: t.instructionAt(step.Cursor)}
+		p.addresses[step.Cursor] = count
+	}
+	count.Count++
+
+	switch step.Opcode {
+	case jumpIfTrueOpcode, jumpIfFalseOpcode:
+		if step.NextCursor <= step.Cursor {
+			p.backJumps[[2]int{step.NextCursor, step.Cursor}]++
+		}
+	case inputOpcode, outputOpcode:
+		now := time.Now()
+		elapsed := now.Sub(p.lastIO)
+		io, ok := p.io[step.Cursor]
+		if !ok {
+			io = &IOCount{Address: step.Cursor, Mnemonic: opcodes[step.Opcode].mnemonic}
+			p.io[step.Cursor] = io
+		}
+		io.Events++
+		io.Instructions += p.sinceIO
+		io.Time += elapsed
+		if elapsed > io.MaxTime {
+			io.MaxTime = elapsed
+		}
+		p.lastIO, p.sinceIO = now, 0
+	}
+}
+
+// ObserveWait counts a tape stopping to wait for input
+func (p *Profiler) ObserveWait(t *Tape, cursor int) {
+	p.waits[cursor]++
+}
+
+// Instructions returns the total number of instructions run
+func (p *Profiler) Instructions() int {
+	return p.instructions
+}
+
+// Addresses returns how many times each instruction ran, most run first
+func (p *Profiler) Addresses() []AddressCount {
+	counts := make([]AddressCount, 0, len(p.addresses))
+	for _, count := range p.addresses {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Address < counts[j].Address
+	})
+	return counts
+}
+
+// Opcodes returns how many instructions with each opcode ran, most run first
+func (p *Profiler) Opcodes() []OpcodeCount {
+	counts := make([]OpcodeCount, 0, len(p.opcodes))
+	for opcode, count := range p.opcodes {
+		counts = append(counts, OpcodeCount{opcodes[opcode].mnemonic, count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Mnemonic < counts[j].Mnemonic
+	})
+	return counts
+}
+
+// Loops returns the loops found by backward jumps, with the most instructions run first
+func (p *Profiler) Loops() []Loop {
+	loops := make([]Loop, 0, len(p.backJumps))
+	for key, iterations := range p.backJumps {
+		loop := Loop{Start: key[0], End: key[1], Iterations: iterations}
+		for address, count := range p.addresses {
+			if address >= loop.Start && address <= loop.End {
+				loop.Instructions += count.Count
+			}
+		}
+		loops = append(loops, loop)
+	}
+	sort.Slice(loops, func(i, j int) bool {
+		if loops[i].Instructions != loops[j].Instructions {
+			return loops[i].Instructions > loops[j].Instructions
+		}
+		return loops[i].Start < loops[j].Start
+	})
+	return loops
+}
+
+// IO returns the input and output instructions which ran, with the most time leading up to them first
+func (p *Profiler) IO() []IOCount {
+	counts := make([]IOCount, 0, len(p.io))
+	for _, count := range p.io {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Time != counts[j].Time {
+			return counts[i].Time > counts[j].Time
+		}
+		return counts[i].Address < counts[j].Address
+	})
+	return counts
+}
+
+// InputWaits returns the input instructions which had to wait for input, most waits first
+func (p *Profiler) InputWaits() []InputWait {
+	waits := make([]InputWait, 0, len(p.waits))
+	for address, count := range p.waits {
+		waits = append(waits, InputWait{address, count})
+	}
+	sort.Slice(waits, func(i, j int) bool {
+		if waits[i].Count != waits[j].Count {
+			return waits[i].Count > waits[j].Count
+		}
+		return waits[i].Address < waits[j].Address
+	})
+	return waits
+}
+
+func percent(count int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(count) / float64(total)
+}
+
+// WriteTable writes the profile as a set of tables, listing at most top addresses and loops
+// (or all of them, if top is 0)
+func (p *Profiler) WriteTable(w io.Writer, top int) error {
+	limit := func(n int) int {
+		if top > 0 && n > top {
+			return top
+		}
+		return n
+	}
+
+	if _, err := fmt.Fprintf(w, "%d instructions\n", p.instructions); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "\nopcode\tcount\t%\t")
+	for _, count := range p.Opcodes() {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t\n", count.Mnemonic, count.Count, percent(count.Count, p.instructions))
+	}
+
+	addresses := p.Addresses()
+	fmt.Fprintln(tw, "\naddress\tcount\t%\t instruction")
+	for _, count := range addresses[:limit(len(addresses))] {
+		fmt.Fprintf(tw, "%04d\t%d\t%.1f\t %s\n", count.Address, count.Count, percent(count.Count, p.instructions), count.Instruction)
+	}
+
+	loops := p.Loops()
+	if len(loops) > 0 {
+		fmt.Fprintln(tw, "\nloop\titerations\tinstructions\t%\t")
+		for _, loop := range loops[:limit(len(loops))] {
+			fmt.Fprintf(tw, "%04d-%04d\t%d\t%d\t%.1f\t\n", loop.Start, loop.End, loop.Iterations, loop.Instructions, percent(loop.Instructions, p.instructions))
+		}
+	}
+
+	if len(p.io) > 0 {
+		fmt.Fprintln(tw, "\nI/O\tevents\tinstructions before\ttime before\tmax time\t")
+		for _, count := range p.IO() {
+			fmt.Fprintf(tw, "%04d %s\t%d\t%d\t%v\t%v\t\n", count.Address, count.Mnemonic, count.Events, count.Instructions, count.Time, count.MaxTime)
+		}
+	}
+
+	if len(p.waits) > 0 {
+		fmt.Fprintln(tw, "\ninput wait\tcount\t")
+		for _, wait := range p.InputWaits() {
+			fmt.Fprintf(tw, "%04d\t%d\t\n", wait.Address, wait.Count)
+		}
+	}
+	return tw.Flush()
+}
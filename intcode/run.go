@@ -0,0 +1,82 @@
+package intcode
+
+import "errors"
+
+// Status is the state a tape is left in when Run returns
+type Status int
+
+const (
+	// Halted means the tape reached a halt instruction
+	Halted Status = iota
+	// NeedsInput means the tape is paused on an input instruction, and can be run again once input is provided
+	NeedsInput
+	// ProducedOutput means the tape output a value, which Run returns
+	ProducedOutput
+	// StepLimitReached means the tape ran as many instructions as it was allowed to
+	StepLimitReached
+	// Faulted means an instruction failed, and Run returns the error
+	Faulted
+)
+
+var statusNames = map[Status]string{
+	Halted:           "halted",
+	NeedsInput:       "needs input",
+	ProducedOutput:   "produced output",
+	StepLimitReached: "step limit reached",
+	Faulted:          "faulted",
+}
+
+func (s Status) String() string {
+	return statusNames[s]
+}
+
+// ErrHalted is returned by RunUntilNextOutput when the tape halts without outputting anything
+var ErrHalted = errors.New("halted")
+
+// Run runs the tape until it halts, needs input, outputs a value, fails, or has run maxSteps
+// instructions (if maxSteps is more than 0). The output value is returned for ProducedOutput, and
+// is taken off the tape. If output was already queued on the tape, the oldest queued value is
+// returned straight away. For Faulted, the error is returned. StepLimitReached is also returned
+// when the tape reaches its own instruction limit, along with a *LimitError.
+//
+// Run can be called again after any status other than Halted and Faulted to carry on running.
+func (t *Tape) Run(maxSteps int) (Status, int, error) {
+	if !t.output.Empty() {
+		return ProducedOutput, t.output.Pop(), nil
+	}
+
+	outputCount := t.outputCount
+	for steps := 0; ; steps++ {
+		if t.halted() {
+			t.notifyHalt()
+			return Halted, 0, nil
+		}
+		if maxSteps > 0 && steps == maxSteps {
+			return StepLimitReached, 0, nil
+		}
+		if err := t.limitReached(); err != nil {
+			return StepLimitReached, 0, err
+		}
+		// A compiled block ends with its first output, so output is checked for after a block just
+		// as after a single instruction
+		limit := 0
+		if maxSteps > 0 {
+			limit = maxSteps - steps
+		}
+		if n := t.runBlock(t.blockLimit(limit)); n > 0 {
+			steps += n - 1
+		} else if err := t.RunNextInstruction(); err != nil {
+			if errors.Is(err, ErrInputExhausted) {
+				return NeedsInput, 0, nil
+			}
+			return Faulted, 0, err
+		}
+
+		if t.outputCount != outputCount {
+			if !t.output.Empty() {
+				return ProducedOutput, t.output.Pop(), nil
+			}
+			return ProducedOutput, t.lastOutput, nil
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package intcode
+
+import (
+	"errors"
+	"testing"
+)
+
+// runExpectation is what one call to Run should return
+type runExpectation struct {
+	status Status
+	value  int
+	err    error
+}
+
+// TestRunStatus steps tapes through each status Run can stop with, in every mode
+func TestRunStatus(t *testing.T) {
+	for _, mode := range benchModes {
+		t.Run(mode.name, func(t *testing.T) {
+			check := func(tape *Tape, maxSteps int, expected runExpectation) {
+				t.Helper()
+				status, value, err := tape.Run(maxSteps)
+				if status != expected.status || value != expected.value || !errors.Is(err, expected.err) {
+					t.Fatalf("got %v, %d, %v, expected %v, %d, %v", status, value, err, expected.status, expected.value, expected.err)
+				}
+			}
+
+			tape := mode.newTape(largeCompare)()
+			check(&tape, 0, runExpectation{NeedsInput, 0, nil})
+			check(&tape, 0, runExpectation{NeedsInput, 0, nil})
+			tape.Input(8)
+			check(&tape, 0, runExpectation{ProducedOutput, 1000, nil})
+			check(&tape, 0, runExpectation{Halted, 0, nil})
+			check(&tape, 0, runExpectation{Halted, 0, nil})
+
+			tape = mode.newTape(countdown)()
+			tape.Input(100)
+			check(&tape, 5, runExpectation{StepLimitReached, 0, nil})
+			if tape.Cursor() != 6 {
+				t.Errorf("stopped at %d after 5 steps, expected 6", tape.Cursor())
+			}
+			check(&tape, 0, runExpectation{ProducedOutput, 5050, nil})
+			check(&tape, 0, runExpectation{Halted, 0, nil})
+
+			tape = mode.newTape(countdown)()
+			tape.Input(100)
+			tape.SetInstructionLimit(10)
+			check(&tape, 0, runExpectation{StepLimitReached, 0, ErrStepLimit})
+
+			tape = mode.newTape([]int{1101, 1, 2, 0, 42})()
+			check(&tape, 0, runExpectation{Faulted, 0, ErrInvalidOpcode})
+		})
+	}
+}
+
+// TestRunQueuedOutput checks that output already queued on the tape is returned before running
+func TestRunQueuedOutput(t *testing.T) {
+	tape := CreateTapeCopy(quine)
+	if err := tape.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range quine {
+		if status, value, err := tape.Run(0); status != ProducedOutput || value != expected || err != nil {
+			t.Fatalf("got %v, %d, %v, expected output %d", status, value, err, expected)
+		}
+	}
+	if status, _, err := tape.Run(0); status != Halted || err != nil {
+		t.Errorf("got %v, %v once the output was used up, expected halted", status, err)
+	}
+}
@@ -0,0 +1,202 @@
+package intcode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// saveVersion is written at the top of every saved tape, and bumped whenever the format changes.
+// Version 2 added big lines, and version 3 the words line.
+const saveVersion = 3
+
+const saveHeader = "intcode-tape"
+
+// ErrUnsupportedVersion is returned when loading a saved tape written by a newer format version
+var ErrUnsupportedVersion = errors.New("unsupported save format version")
+
+// eachPage calls f with the address and cells of every allocated page, in address order
+func (m *memory) eachPage(f func(address int, cells []int) error) error {
+	for i, p := range m.dense {
+		if p != nil {
+			if err := f(i*pageSize, p.cells[:]); err != nil {
+				return err
+			}
+		}
+	}
+
+	indexes := make([]int, 0, len(m.sparse))
+	for i := range m.sparse {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		if err := f(i*pageSize, m.sparse[i].cells[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save writes the full state of the tape to w: memory, cursor, relative base, word mode and
+// queued input and output. The format is line-based text:
+//
+//	intcode-tape 3
+//	cursor 12
+//	relative-base 0
+//	words big
+//	input 1,2
+//	output 5
+//	memory 0 1101,5,0,18
+//	big 3 18446744073709551616
+//
+// with one memory line for each run of written cells, giving its start address and values, and
+// one big line for each cell holding a value too large for an int (see BigWords).
+func (t *Tape) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s %d\n", saveHeader, saveVersion)
+	fmt.Fprintf(bw, "cursor %d\n", t.cursor)
+	fmt.Fprintf(bw, "relative-base %d\n", t.relativeBase)
+	fmt.Fprintf(bw, "words %s\n", t.words)
+	fmt.Fprintf(bw, "input %s\n", FormatTapeData(t.PendingInput()))
+	fmt.Fprintf(bw, "output %s\n", FormatTapeData(t.PendingOutput()))
+
+	err := t.data.eachPage(func(address int, cells []int) error {
+		start, end := 0, len(cells)
+		for start < end && cells[start] == 0 {
+			start++
+		}
+		for end > start && cells[end-1] == 0 {
+			end--
+		}
+		if start == end {
+			return nil
+		}
+		_, err := fmt.Fprintf(bw, "memory %d %s\n", address+start, FormatTapeData(cells[start:end]))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	addresses := make([]int, 0, len(t.bigs))
+	for address := range t.bigs {
+		addresses = append(addresses, address)
+	}
+	sort.Ints(addresses)
+	for _, address := range addresses {
+		fmt.Fprintf(bw, "big %d %s\n", address, t.bigs[address])
+	}
+	return bw.Flush()
+}
+
+// parseSavedValues parses a list of values written by Save. Unlike ParseValues it rejects empty
+// fields and spaces, since they can only come from a corrupt save.
+func parseSavedValues(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	fields := strings.Split(s, ",")
+	values := make([]int, len(fields))
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", field)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// Load replaces the state of the tape with one written by Save. Observers and connected
+// inputs and outputs are kept, as are the instruction count and limit and the history limit,
+// but the history itself is discarded. Saves older than version 3 keep the tape's word mode.
+func (t *Tape) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("intcode: empty save")
+	}
+	var version int
+	if _, err := fmt.Sscanf(scanner.Text(), saveHeader+" %d", &version); err != nil {
+		return fmt.Errorf("intcode: not a saved tape")
+	}
+	if version > saveVersion {
+		return fmt.Errorf("intcode: %w: %d", ErrUnsupportedVersion, version)
+	}
+
+	loaded := Tape{data: newMemory(nil), observers: t.observers, source: t.source, sink: t.sink, words: t.words,
+		history: t.history.emptied(), instructions: t.instructions, maxInstructions: t.maxInstructions}
+	for line := 2; scanner.Scan(); line++ {
+		fields := strings.SplitN(scanner.Text(), " ", 3)
+		key := fields[0]
+		args := fields[1:]
+
+		var err error
+		switch {
+		case key == "cursor" && len(args) == 1:
+			loaded.cursor, err = strconv.Atoi(args[0])
+		case key == "relative-base" && len(args) == 1:
+			loaded.relativeBase, err = strconv.Atoi(args[0])
+		case key == "words" && len(args) == 1:
+			loaded.words, err = ParseWordMode(args[0])
+		case (key == "input" || key == "output") && len(args) <= 1:
+			var values []int
+			if len(args) == 1 {
+				values, err = parseSavedValues(args[0])
+			}
+			if key == "input" {
+				loaded.input = newQueue(values)
+			} else {
+				loaded.output = newQueue(values)
+			}
+		case key == "memory" && len(args) == 2:
+			var address int
+			var values []int
+			if address, err = strconv.Atoi(args[0]); err == nil {
+				values, err = parseSavedValues(args[1])
+			}
+			for i, x := range values {
+				if err = loaded.data.Write(address+i, x); err != nil {
+					break
+				}
+			}
+		case key == "big" && len(args) == 2:
+			var address int
+			x, ok := new(big.Int).SetString(args[1], 10)
+			if address, err = strconv.Atoi(args[0]); err == nil && !ok {
+				err = fmt.Errorf("invalid value %q", args[1])
+			}
+			if err == nil {
+				if loaded.bigs == nil {
+					loaded.bigs = map[int]*big.Int{}
+				}
+				loaded.bigs[address] = x
+			}
+		default:
+			err = fmt.Errorf("unexpected %q", key)
+		}
+
+		if err != nil {
+			return fmt.Errorf("intcode: save line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if program := t.program(); program != nil {
+		loaded.compiled = program.link(loaded.data)
+	}
+	*t = loaded
+	return nil
+}
@@ -0,0 +1,91 @@
+package intcode
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSaveRoundTrip saves a tape part way through a run, and checks that loading it into another
+// tape gives the same state, which finishes the run the same way
+func TestSaveRoundTrip(t *testing.T) {
+	tape := CreateTapeCopy(largeCompare)
+	tape.SetWordMode(CheckedWords)
+	tape.Input(8)
+	tape.Input(9)
+	for i := 0; i < 5; i++ {
+		if err := tape.RunNextInstruction(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tape.Set(1000000, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved bytes.Buffer
+	if err := tape.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded := CreateTapeCopy(nil)
+	if err := loaded.Load(bytes.NewReader(saved.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Cursor() != tape.Cursor() || loaded.RelativeBase() != tape.RelativeBase() || loaded.WordMode() != tape.WordMode() {
+		t.Errorf("loaded at %d with relative base %d in %v, expected %d, %d, %v", loaded.Cursor(), loaded.RelativeBase(),
+			loaded.WordMode(), tape.Cursor(), tape.RelativeBase(), tape.WordMode())
+	}
+	if !loaded.data.equal(tape.data) {
+		t.Errorf("loaded memory differs")
+	}
+	if !reflect.DeepEqual(loaded.PendingInput(), tape.PendingInput()) {
+		t.Errorf("loaded input %v, expected %v", loaded.PendingInput(), tape.PendingInput())
+	}
+
+	if err := tape.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.PendingOutput(), tape.PendingOutput()) {
+		t.Errorf("loaded tape output %v, expected %v", loaded.PendingOutput(), tape.PendingOutput())
+	}
+}
+
+var corruptSaves = []struct {
+	name string
+	save string
+}{
+	{"empty", ""},
+	{"not a save", "1,2,3\n"},
+	{"empty memory value", "intcode-tape 3\nmemory 0 1,,3,99\n"},
+	{"spaced memory value", "intcode-tape 3\nmemory 0 1, 2\n"},
+	{"empty input value", "intcode-tape 3\ninput 1,\n"},
+	{"bad cursor", "intcode-tape 3\ncursor x\n"},
+	{"bad word mode", "intcode-tape 3\nwords huge\n"},
+	{"bad big value", "intcode-tape 3\nbig 0 12x\n"},
+	{"unknown line", "intcode-tape 3\nregisters 1\n"},
+}
+
+// TestLoadCorrupt checks that corrupt saves are rejected, and leave the tape as it was
+func TestLoadCorrupt(t *testing.T) {
+	for _, c := range corruptSaves {
+		t.Run(c.name, func(t *testing.T) {
+			tape := CreateTapeCopy(echo)
+			if err := tape.Load(strings.NewReader(c.save)); err == nil {
+				t.Fatal("loaded without error")
+			}
+			if !tape.data.equal(newMemory(echo)) {
+				t.Errorf("failed load changed the tape")
+			}
+		})
+	}
+
+	tape := CreateTapeCopy(nil)
+	if err := tape.Load(strings.NewReader("intcode-tape 99\n")); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("got error %v loading a newer version, expected %v", err, ErrUnsupportedVersion)
+	}
+}
@@ -0,0 +1,73 @@
+package intcode
+
+import (
+	"intqueue"
+	"math/big"
+)
+
+// Snapshot is a saved copy of the state of a tape: its memory, cursor, relative base and
+// queued input and output. Taking a snapshot is cheap, as memory is shared with the tape
+// until either of them changes it. Connected inputs and outputs are not part of the snapshot.
+type Snapshot struct {
+	data         *memory
+	cursor       int
+	relativeBase int
+	input        []int
+	output       []int
+	bigs         map[int]*big.Int
+	program      *Program
+}
+
+// Cursor returns the address of the next instruction to run when the snapshot was taken
+func (s Snapshot) Cursor() int {
+	return s.cursor
+}
+
+func newQueue(values []int) intqueue.Queue {
+	q := intqueue.Queue{}
+	for _, x := range values {
+		q.Push(x)
+	}
+	return q
+}
+
+// Snapshot saves the current state of the tape, so it can be returned to later with Restore
+func (t *Tape) Snapshot() Snapshot {
+	return Snapshot{
+		data:         t.data.freeze(),
+		cursor:       t.cursor,
+		relativeBase: t.relativeBase,
+		input:        t.PendingInput(),
+		output:       t.PendingOutput(),
+		bigs:         copyBigs(t.bigs),
+		program:      t.program(),
+	}
+}
+
+// Restore returns the tape to the state saved in a snapshot. The snapshot is left untouched,
+// so it can be restored again later, including by several tapes on different goroutines at once.
+// The tape's history is discarded.
+func (t *Tape) Restore(s Snapshot) {
+	t.data = s.data.clone()
+	t.cursor = s.cursor
+	t.relativeBase = s.relativeBase
+	t.input = newQueue(s.input)
+	t.output = newQueue(s.output)
+	t.bigs = copyBigs(s.bigs)
+	t.waiting = false
+	t.history = t.history.emptied()
+	t.cache = nil
+	t.compiled = nil
+	if s.program != nil {
+		t.compiled = s.program.link(t.data)
+	}
+}
+
+// Clone returns a new tape in the same state as this one, which can then be run independently.
+// Observers and connected inputs and outputs are not carried over to the clone, but its word
+// mode and instruction limit are. The clone's instruction count starts from 0.
+func (t *Tape) Clone() Tape {
+	clone := Tape{words: t.words, uncached: t.uncached, maxInstructions: t.maxInstructions}
+	clone.Restore(t.Snapshot())
+	return clone
+}
@@ -0,0 +1,80 @@
+package intcode
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// TestCloneIsolation checks that a tape and its clone share memory until one of them writes to
+// it, and that neither sees the other's writes afterwards
+func TestCloneIsolation(t *testing.T) {
+	tape := CreateTapeCopy(largeCompare)
+	if err := tape.Set(100000, 5); err != nil {
+		t.Fatal(err)
+	}
+	clone := tape.Clone()
+	if clone.data.dense[0] != tape.data.dense[0] {
+		t.Errorf("clone copied memory before writing to it")
+	}
+
+	if err := clone.Set(0, 42); err != nil {
+		t.Fatal(err)
+	}
+	if err := clone.Set(100000, 6); err != nil {
+		t.Fatal(err)
+	}
+	if err := tape.Set(1, 43); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		tape     *Tape
+		address  int
+		expected int
+	}{
+		{&tape, 0, largeCompare[0]}, {&tape, 1, 43}, {&tape, 100000, 5},
+		{&clone, 0, 42}, {&clone, 1, largeCompare[1]}, {&clone, 100000, 6},
+	} {
+		if value, _ := c.tape.Peek(c.address); value != c.expected {
+			t.Errorf("got %d at %d, expected %d", value, c.address, c.expected)
+		}
+	}
+}
+
+// TestSnapshotRestore runs a tape on from a snapshot several times, including on several
+// goroutines at once, and checks each run gives the same answer and leaves the snapshot alone
+func TestSnapshotRestore(t *testing.T) {
+	tape := CreateTapeCopy(largeCompare)
+	tape.Input(8)
+	snapshot := tape.Snapshot()
+
+	run := func() ([]int, error) {
+		var tape Tape
+		tape.Restore(snapshot)
+		err := tape.RunUntilHalt()
+		return tape.PendingOutput(), err
+	}
+	output, err := run()
+	if err != nil || !reflect.DeepEqual(output, []int{1000}) {
+		t.Fatalf("got %v (%v), expected [1000]", output, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if output, err := run(); err != nil || !reflect.DeepEqual(output, []int{1000}) {
+				t.Errorf("got %v (%v) restoring concurrently, expected [1000]", output, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !snapshot.data.equal(newMemory(largeCompare)) || snapshot.Cursor() != 0 {
+		t.Errorf("running restored tapes changed the snapshot")
+	}
+	if !tape.data.equal(newMemory(largeCompare)) {
+		t.Errorf("running restored tapes changed the original tape")
+	}
+}
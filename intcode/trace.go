@@ -0,0 +1,138 @@
+package intcode
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TraceFormat selects how a Tracer writes each instruction
+type TraceFormat int
+
+const (
+	// TraceText writes one aligned, human readable line per instruction
+	TraceText TraceFormat = iota
+	// TraceJSON writes one JSON object per line
+	TraceJSON
+)
+
+// TraceRecord is a single traced instruction, as written in the JSON trace format
+type TraceRecord struct {
+	// Step counts every instruction run since the tracer was attached, including filtered ones,
+	// so that records from two runs of the same program line up
+	Step        int    `json:"step"`
+	Cursor      int    `json:"cursor"`
+	Instruction int    `json:"instruction"`
+	Mnemonic    string `json:"op"`
+	// Operands are the resolved values of the params which are read
+	Operands     []int `json:"operands"`
+	Destination  *int  `json:"dest,omitempty"`
+	Written      *int  `json:"value,omitempty"`
+	RelativeBase int   `json:"rb"`
+	NextCursor   int   `json:"next"`
+}
+
+// Tracer is an Observer which writes every instruction a tape runs to a writer
+type Tracer struct {
+	w        io.Writer
+	format   TraceFormat
+	encoder  *json.Encoder
+	steps    int
+	from, to int
+	ranged   bool
+	opcodes  map[int]bool
+	err      error
+}
+
+// NewTracer creates a tracer writing to w. Attach it to a tape to start tracing.
+func NewTracer(w io.Writer, format TraceFormat) *Tracer {
+	return &Tracer{w: w, format: format, encoder: json.NewEncoder(w)}
+}
+
+// FilterAddresses only traces instructions at addresses from (inclusive) to to (exclusive)
+func (tr *Tracer) FilterAddresses(from int, to int) {
+	tr.from, tr.to, tr.ranged = from, to, true
+}
+
+// FilterOpcodes only traces instructions with one of the given opcodes
+func (tr *Tracer) FilterOpcodes(opcodes ...int) {
+	tr.opcodes = map[int]bool{}
+	for _, opcode := range opcodes {
+		tr.opcodes[opcode] = true
+	}
+}
+
+// Err returns the first error hit while writing the trace
+func (tr *Tracer) Err() error {
+	return tr.err
+}
+
+// Observe writes a step to the trace, unless it is filtered out
+func (tr *Tracer) Observe(t *Tape, step *Step) {
+	tr.steps++
+	if tr.err != nil {
+		return
+	}
+	if tr.ranged && (step.Cursor < tr.from || step.Cursor >= tr.to) {
+		return
+	}
+	if tr.opcodes != nil && !tr.opcodes[step.Opcode] {
+		return
+	}
+
+	record := newTraceRecord(tr.steps, step)
+	if tr.format == TraceJSON {
+		tr.err = tr.encoder.Encode(record)
+	} else {
+		_, tr.err = fmt.Fprintln(tr.w, record)
+	}
+}
+
+func newTraceRecord(number int, step *Step) TraceRecord {
+	record := TraceRecord{
+		Step:         number,
+		Cursor:       step.Cursor,
+		Instruction:  step.Instruction,
+		Mnemonic:     opcodes[step.Opcode].mnemonic,
+		Operands:     []int{},
+		RelativeBase: step.NextRelativeBase,
+		NextCursor:   step.NextCursor,
+	}
+
+	destination := opcodes[step.Opcode].destination
+	for i, operand := range step.Operands {
+		if i == destination {
+			address := operand
+			record.Destination = &address
+			continue
+		}
+		record.Operands = append(record.Operands, operand)
+	}
+	if len(step.Writes) > 0 {
+		written := step.Writes[0].Value
+		record.Written = &written
+	}
+	return record
+}
+
+// String formats the record as a line of the text trace format
+func (r TraceRecord) String() string {
+	operands := make([]string, len(r.Operands))
+	for i, x := range r.Operands {
+		operands[i] = strconv.Itoa(x)
+	}
+
+	var effect string
+	switch {
+	case r.Destination != nil && r.Written != nil:
+		effect = fmt.Sprintf(" -> [%d] = %d", *r.Destination, *r.Written)
+	case r.Destination != nil:
+		effect = " -> (discarded)"
+	case r.NextCursor != r.Cursor+len(r.Operands)+1:
+		effect = fmt.Sprintf(" -> jump %d", r.NextCursor)
+	}
+
+	return fmt.Sprintf("%8d  %04d  %-3s  %s%s  rb=%d", r.Step, r.Cursor, r.Mnemonic, strings.Join(operands, ", "), effect, r.RelativeBase)
+}
@@ -0,0 +1,79 @@
+package intcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func trace(t *testing.T, program []int, input []int, format TraceFormat, filter func(tr *Tracer)) string {
+	t.Helper()
+	var out bytes.Buffer
+	tracer := NewTracer(&out, format)
+	if filter != nil {
+		filter(tracer)
+	}
+	tape := CreateTapeCopy(program)
+	tape.Attach(tracer)
+	for _, x := range input {
+		tape.Input(x)
+	}
+	if err := tape.RunUntilHalt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracer.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestTraceText(t *testing.T) {
+	expected := "       1  0000  add  30, 40 -> [3] = 70  rb=0\n" +
+		"       2  0004  mul  70, 50 -> [0] = 3500  rb=0\n"
+	if got := trace(t, day2Example, nil, TraceText, nil); got != expected {
+		t.Errorf("got\n%s\nexpected\n%s", got, expected)
+	}
+
+	expected = "       1  0000  in    -> [0] = 7  rb=0\n" +
+		"       2  0002  out  7  rb=0\n"
+	if got := trace(t, echo, []int{7}, TraceText, nil); got != expected {
+		t.Errorf("got\n%s\nexpected\n%s", got, expected)
+	}
+}
+
+func TestTraceJSON(t *testing.T) {
+	intPointer := func(x int) *int { return &x }
+	expected := []TraceRecord{
+		{Step: 1, Cursor: 0, Instruction: 1, Mnemonic: "add", Operands: []int{30, 40}, Destination: intPointer(3), Written: intPointer(70), NextCursor: 4},
+		{Step: 2, Cursor: 4, Instruction: 2, Mnemonic: "mul", Operands: []int{70, 50}, Destination: intPointer(0), Written: intPointer(3500), NextCursor: 8},
+	}
+
+	lines := strings.Split(strings.TrimSpace(trace(t, day2Example, nil, TraceJSON, nil)), "\n")
+	var records []TraceRecord
+	for _, line := range lines {
+		var r TraceRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("%v in %q", err, line)
+		}
+		records = append(records, r)
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("got %+v, expected %+v", records, expected)
+	}
+}
+
+// TestTraceFilters checks that filtered out instructions aren't written, but are still counted
+func TestTraceFilters(t *testing.T) {
+	expected := "       2  0004  mul  70, 50 -> [0] = 3500  rb=0\n"
+	filters := map[string]func(tr *Tracer){
+		"addresses": func(tr *Tracer) { tr.FilterAddresses(4, 8) },
+		"opcodes":   func(tr *Tracer) { tr.FilterOpcodes(multiplyOpcode) },
+	}
+	for name, filter := range filters {
+		if got := trace(t, day2Example, nil, TraceText, filter); got != expected {
+			t.Errorf("%s: got\n%s\nexpected\n%s", name, got, expected)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package intcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// transcriptHeader is the first line of a saved transcript, so other files aren't mistaken for one
+const transcriptHeader = "intcode-transcript 1"
+
+// TranscriptKind is what a transcript entry records
+type TranscriptKind int
+
+const (
+	// TranscriptInput is a line typed in, without its newline
+	TranscriptInput TranscriptKind = iota
+	// TranscriptText is a line of text output, with its newline unless it is the last text before
+	// some input or the end of the session
+	TranscriptText
+	// TranscriptValue is an output value which isn't a character
+	TranscriptValue
+)
+
+var transcriptKindNames = [...]string{"in", "out", "value"}
+
+func (k TranscriptKind) String() string {
+	if k < 0 || int(k) >= len(transcriptKindNames) {
+		return fmt.Sprintf("TranscriptKind(%d)", int(k))
+	}
+	return transcriptKindNames[k]
+}
+
+// TranscriptEntry is one line typed in, or one piece of output, in a transcript
+type TranscriptEntry struct {
+	Kind  TranscriptKind
+	Text  string
+	Value int
+}
+
+func (e TranscriptEntry) String() string {
+	if e.Kind == TranscriptValue {
+		return fmt.Sprintf("%v %d", e.Kind, e.Value)
+	}
+	return fmt.Sprintf("%v %q", e.Kind, e.Text)
+}
+
+// Transcript is a record of a session with a text program: every line typed in, and everything
+// output, in order. Replaying it against the same program should give exactly the same output.
+type Transcript struct {
+	Entries []TranscriptEntry
+}
+
+// addInput records a line typed in
+func (tr *Transcript) addInput(line string) {
+	tr.Entries = append(tr.Entries, TranscriptEntry{Kind: TranscriptInput, Text: line})
+}
+
+// addOutput records an output value. Text is split into lines, so that a transcript doesn't
+// depend on how the output was broken up.
+func (tr *Transcript) addOutput(x int) {
+	if !IsASCII(x) {
+		tr.Entries = append(tr.Entries, TranscriptEntry{Kind: TranscriptValue, Value: x})
+		return
+	}
+	if n := len(tr.Entries); n > 0 {
+		last := &tr.Entries[n-1]
+		if last.Kind == TranscriptText && !strings.HasSuffix(last.Text, "\n") {
+			last.Text += string(rune(x))
+			return
+		}
+	}
+	tr.Entries = append(tr.Entries, TranscriptEntry{Kind: TranscriptText, Text: string(rune(x))})
+}
+
+// Inputs returns the lines typed in during the session
+func (tr *Transcript) Inputs() []string {
+	var inputs []string
+	for _, e := range tr.Entries {
+		if e.Kind == TranscriptInput {
+			inputs = append(inputs, e.Text)
+		}
+	}
+	return inputs
+}
+
+// Save writes the transcript as text, one entry per line
+func (tr *Transcript) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, transcriptHeader)
+	for _, e := range tr.Entries {
+		fmt.Fprintln(bw, e)
+	}
+	return bw.Flush()
+}
+
+// LoadTranscript reads a transcript written by Save
+func LoadTranscript(r io.Reader) (*Transcript, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || scanner.Text() != transcriptHeader {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("intcode: not a transcript file")
+	}
+
+	tr := &Transcript{}
+	for line := 2; scanner.Scan(); line++ {
+		text := scanner.Text()
+		var e TranscriptEntry
+		var err error
+		switch {
+		case strings.HasPrefix(text, "in "):
+			e.Kind = TranscriptInput
+			e.Text, err = strconv.Unquote(text[len("in "):])
+		case strings.HasPrefix(text, "out "):
+			e.Kind = TranscriptText
+			e.Text, err = strconv.Unquote(text[len("out "):])
+		case strings.HasPrefix(text, "value "):
+			e.Kind = TranscriptValue
+			e.Value, err = strconv.Atoi(text[len("value "):])
+		default:
+			err = fmt.Errorf("unexpected %q", text)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("intcode: transcript line %d: %w", line, err)
+		}
+		tr.Entries = append(tr.Entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// TranscriptMismatch is returned by Replay when a replayed session differs from the transcript.
+// Entry is the index of the first entry which differs, and Expected or Actual is nil if the
+// transcript or the replay ended there.
+type TranscriptMismatch struct {
+	Entry    int
+	Expected *TranscriptEntry
+	Actual   *TranscriptEntry
+}
+
+func (e *TranscriptMismatch) Error() string {
+	describe := func(entry *TranscriptEntry) string {
+		if entry == nil {
+			return "the end of the session"
+		}
+		return entry.String()
+	}
+	return fmt.Sprintf("intcode: transcript entry %d: expected %s, got %s", e.Entry, describe(e.Expected), describe(e.Actual))
+}
+
+// Record runs the tape interactively like Interact, and returns a transcript of the session. The
+// transcript of as much as happened is returned even if there is an error.
+func (a *ASCII) Record(r io.Reader, w io.Writer) (*Transcript, error) {
+	tr := &Transcript{}
+	a.transcript = tr
+	defer func() {
+		a.transcript = nil
+	}()
+	err := a.Interact(r, w)
+	return tr, err
+}
+
+// Replay runs a tape with the lines typed in during a recorded session, each given to the tape
+// when it waits for input as it was when the session was recorded, and checks that everything the
+// tape outputs is the same as in the transcript. A difference is returned as a *TranscriptMismatch.
+func (tr *Transcript) Replay(t *Tape) error {
+	a := NewASCII(t)
+	actual := &Transcript{}
+	a.transcript = actual
+
+	inputs := tr.Inputs()
+	for {
+		if err := a.Run(); err != nil {
+			return err
+		}
+		if a.Halted() || len(inputs) == 0 {
+			break
+		}
+		actual.addInput(inputs[0])
+		a.WriteLine(inputs[0])
+		inputs = inputs[1:]
+	}
+	return tr.compare(actual)
+}
+
+// compare returns a *TranscriptMismatch for the first entry which differs between the transcripts
+func (tr *Transcript) compare(actual *Transcript) error {
+	for i := 0; i < len(tr.Entries) || i < len(actual.Entries); i++ {
+		var expected, got *TranscriptEntry
+		if i < len(tr.Entries) {
+			expected = &tr.Entries[i]
+		}
+		if i < len(actual.Entries) {
+			got = &actual.Entries[i]
+		}
+		if expected == nil || got == nil || *expected != *got {
+			return &TranscriptMismatch{Entry: i, Expected: expected, Actual: got}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package intcode
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// WordMode selects what a tape does when arithmetic overflows an int
+type WordMode int
+
+const (
+	// WrapWords wraps around silently, as Go ints do. This is the default.
+	WrapWords WordMode = iota
+	// CheckedWords fails the instruction with ErrOverflow
+	CheckedWords
+	// BigWords keeps the exact result, using math/big for values which don't fit in an int
+	BigWords
+)
+
+var wordModeNames = map[WordMode]string{
+	WrapWords:    "wrap",
+	CheckedWords: "checked",
+	BigWords:     "big",
+}
+
+func (m WordMode) String() string {
+	return wordModeNames[m]
+}
+
+// ParseWordMode returns the word mode with the given name, as returned by WordMode.String
+func ParseWordMode(name string) (WordMode, error) {
+	for m, n := range wordModeNames {
+		if n == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown word mode %q", name)
+}
+
+// BigOutput is an Output which can also receive values too large for an int. A tape in BigWords
+// mode fails with ErrOverflow if it outputs such a value to anything else.
+type BigOutput interface {
+	Output
+	WriteBig(x *big.Int) error
+}
+
+// BigOutputFunc adapts a function to a BigOutput, which receives every value as a *big.Int
+type BigOutputFunc func(x *big.Int) error
+
+func (f BigOutputFunc) Write(x int) error {
+	return f(big.NewInt(int64(x)))
+}
+
+func (f BigOutputFunc) WriteBig(x *big.Int) error {
+	return f(x)
+}
+
+// SetWordMode changes how the tape handles arithmetic overflow. Compiled tapes are interpreted
+// in any mode other than WrapWords.
+func (t *Tape) SetWordMode(m WordMode) {
+	t.words = m
+}
+
+// WordMode returns how the tape handles arithmetic overflow
+func (t Tape) WordMode() WordMode {
+	return t.words
+}
+
+// PeekBig returns the value at an address in the tape's memory, including values too large for Peek
+func (t Tape) PeekBig(address int) (*big.Int, error) {
+	if x, ok := t.bigs[address]; ok {
+		return new(big.Int).Set(x), nil
+	}
+	value, err := t.data.Read(address)
+	if err != nil {
+		return nil, err
+	}
+	return big.NewInt(int64(value)), nil
+}
+
+// combine applies an add, multiply, less than or equals opcode to x and y. ok is false if the
+// result overflowed.
+func combine(opcode int, x int, y int) (result int, ok bool) {
+	switch opcode {
+	case addOpcode:
+		result = x + y
+		// Overflow flips the sign of the result away from the sign both operands share
+		return result, (x^result)&(y^result) >= 0
+	case multiplyOpcode:
+		if x == 0 || y == 0 {
+			return 0, true
+		}
+		result = x * y
+		return result, result/y == x && !(y == -1 && x == math.MinInt)
+	case lessThanOpcode:
+		if x < y {
+			return 1, true
+		}
+		return 0, true
+	default:
+		if x == y {
+			return 1, true
+		}
+		return 0, true
+	}
+}
+
+// bigAllowed returns whether param i of an instruction may hold a value too large for an int
+func bigAllowed(opcode int, i int) bool {
+	switch opcode {
+	case addOpcode, multiplyOpcode, lessThanOpcode, equalsOpcode:
+		return i < 2
+	case jumpIfTrueOpcode, jumpIfFalseOpcode, outputOpcode:
+		return i == 0
+	default:
+		return false
+	}
+}
+
+// bigOperands returns the values of any params which refer to cells holding values too large for
+// an int. Those params resolve to the wrong value as ints, so they fail unless the instruction
+// can take a big value there.
+func (t *Tape) bigOperands(opcode int, params []param, destination int) (wide [3]*big.Int, err error) {
+	for i, p := range params {
+		if i == destination || p.mode == immediateMode {
+			continue
+		}
+		address, _ := t.reference(p)
+		x, ok := t.bigs[address]
+		if !ok {
+			continue
+		}
+		if !bigAllowed(opcode, i) {
+			return wide, fmt.Errorf("%w: %s at %d can't be used as an int", ErrOverflow, x, address)
+		}
+		wide[i] = x
+	}
+	return wide, nil
+}
+
+// arithmetic runs an add, multiply, less than or equals instruction in the tape's word mode.
+// wideX and wideY are the operands if they are too large for an int, or nil.
+func (t *Tape) arithmetic(opcode int, destination param, x int, y int, wideX *big.Int, wideY *big.Int) error {
+	if wideX == nil && wideY == nil {
+		result, ok := combine(opcode, x, y)
+		if ok || t.words == WrapWords {
+			t.store(destination, result)
+			return nil
+		}
+		if t.words == CheckedWords {
+			return fmt.Errorf("%w: %d %s %d", ErrOverflow, x, opcodes[opcode].mnemonic, y)
+		}
+	}
+
+	if wideX == nil {
+		wideX = big.NewInt(int64(x))
+	}
+	if wideY == nil {
+		wideY = big.NewInt(int64(y))
+	}
+	result := new(big.Int)
+	switch opcode {
+	case addOpcode:
+		result.Add(wideX, wideY)
+	case multiplyOpcode:
+		result.Mul(wideX, wideY)
+	case lessThanOpcode:
+		if wideX.Cmp(wideY) < 0 {
+			result.SetInt64(1)
+		}
+	case equalsOpcode:
+		if wideX.Cmp(wideY) == 0 {
+			result.SetInt64(1)
+		}
+	}
+	t.storeBig(destination, result)
+	return nil
+}
+
+// storeBig writes a result which may be too large for an int to its destination param
+func (t *Tape) storeBig(p param, x *big.Int) {
+	// The cell holds the low bits, so that Peek and the tracer show something sensible
+	t.store(p, int(x.Int64()))
+	if x.IsInt64() {
+		return
+	}
+	address, _ := t.reference(p)
+	if t.bigs == nil {
+		t.bigs = map[int]*big.Int{}
+	}
+	t.bigs[address] = x
+}
+
+// writeBigOutput outputs a value which is too large for an int
+func (t *Tape) writeBigOutput(x *big.Int) error {
+	sink, ok := t.sink.(BigOutput)
+	if !ok {
+		return fmt.Errorf("%w: output %s needs a BigOutput", ErrOverflow, x)
+	}
+	if err := sink.WriteBig(x); err != nil {
+		return err
+	}
+	t.outputCount++
+	t.lastOutput = int(x.Int64())
+	return nil
+}
+
+// copyBigs copies the cells holding values too large for an int. The values themselves are
+// never changed once stored, so they are shared.
+func copyBigs(bigs map[int]*big.Int) map[int]*big.Int {
+	if len(bigs) == 0 {
+		return nil
+	}
+	c := make(map[int]*big.Int, len(bigs))
+	for address, x := range bigs {
+		c[address] = x
+	}
+	return c
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"advent-2019/intcode"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	machines := flag.Int("machines", 50, "how many machines to run")
+	nat := flag.Bool("nat", true, "put a NAT at address 255, which wakes the network up when it goes idle")
+	logPackets := flag.Bool("log", false, "print every packet as it is sent")
+	concurrent := flag.Bool("concurrent", false, "run each machine on its own goroutine")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: nic [-machines n] [-nat] [-log] [-concurrent] <tape file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *machines < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	data, err := intcode.ReadTapeData(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Every machine runs the same program, so it is compiled once and shared
+	program := intcode.Compile(data)
+	network := intcode.NewPacketNetwork()
+	for i := 0; i < *machines; i++ {
+		tape := program.NewTape()
+		network.Add(&tape)
+	}
+	if *logPackets {
+		network.OnPacket(func(p intcode.Packet) {
+			fmt.Println(p)
+		})
+	}
+	var monitor *intcode.NAT
+	if *nat {
+		monitor = &intcode.NAT{}
+		network.SetMonitor(monitor)
+	}
+
+	scheduler := intcode.RoundRobin
+	if *concurrent {
+		scheduler = intcode.Concurrent
+	}
+	if err := network.Run(scheduler); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Packets sent:", network.Sent())
+	if monitor == nil {
+		return
+	}
+	if len(monitor.Received) > 0 {
+		fmt.Println("First packet to the NAT:", monitor.Received[0])
+	}
+	if y, ok := monitor.Repeated(); ok {
+		fmt.Println("First Y sent twice in a row by the NAT:", y)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"advent-2019/intcode"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	input := flag.String("input", "", "comma-separated values to queue as input")
+	repeat := flag.Bool("repeat", false, "keep giving the last input value once the queued input runs out")
+	steps := flag.Int("steps", 0, "stop after this many instructions (0 for no limit)")
+	top := flag.Int("top", 20, "how many addresses and loops to list (0 for all)")
+	pprofPath := flag.String("pprof", "", "file to write a profile for go tool pprof to")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: profile [flags] <tape file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := intcode.ReadTapeData(file)
+	file.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	inputs, err := intcode.ParseValues(*input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tape := intcode.CreateTapeCopy(data)
+	if *repeat && len(inputs) > 0 {
+		last := inputs[len(inputs)-1]
+		tape.ConnectInput(intcode.InputFunc(func() (int, bool) {
+			if len(inputs) == 0 {
+				return last, true
+			}
+			x := inputs[0]
+			inputs = inputs[1:]
+			return x, true
+		}))
+	} else {
+		for _, x := range inputs {
+			tape.Input(x)
+		}
+	}
+
+	profiler := intcode.NewProfiler()
+	tape.Attach(profiler)
+
+	// Output is only counted, so run until something other than output stops the tape
+	remaining := func() int {
+		if *steps == 0 {
+			return 0
+		}
+		return *steps - profiler.Instructions()
+	}
+	var outputs int
+	status, _, runErr := tape.Run(*steps)
+	for status == intcode.ProducedOutput {
+		outputs++
+		if *steps > 0 && remaining() <= 0 {
+			status = intcode.StepLimitReached
+			break
+		}
+		status, _, runErr = tape.Run(remaining())
+	}
+	fmt.Printf("%s after %d outputs\n\n", status, outputs)
+
+	if err := profiler.WriteTable(os.Stdout, *top); err != nil {
+		log.Fatal(err)
+	}
+
+	if *pprofPath != "" {
+		out, err := os.Create(*pprofPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := profiler.WritePprof(out); err != nil {
+			log.Fatal(err)
+		}
+		if err := out.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if runErr != nil {
+		log.Fatal(runErr)
+	}
+}
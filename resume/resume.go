@@ -0,0 +1,84 @@
+package main
+
+import (
+	"advent-2019/intcode"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func load(path string) intcode.Tape {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	tape := intcode.Tape{}
+	if err := tape.Load(file); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	return tape
+}
+
+func save(tape *intcode.Tape, path string) {
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := tape.Save(file); err != nil {
+		log.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	input := flag.String("input", "", "comma-separated values to queue as input before resuming")
+	savePath := flag.String("save", "", "where to save the tape if it stops waiting for input (defaults to the state file)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: resume [-input values] [-save file] <state file>")
+		fmt.Fprintln(os.Stderr, "Runs a saved tape until it halts or needs more input, printing its output.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *savePath == "" {
+		*savePath = flag.Arg(0)
+	}
+
+	tape := load(flag.Arg(0))
+	for _, field := range strings.Split(*input, ",") {
+		if field == "" {
+			continue
+		}
+		x, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			log.Fatal("Invalid input: ", field)
+		}
+		tape.Input(x)
+	}
+
+	err := tape.RunUntilHalt()
+	for _, x := range tape.PendingOutput() {
+		fmt.Println(x)
+	}
+	tape.ClearOutput()
+
+	if errors.Is(err, intcode.ErrInputExhausted) {
+		save(&tape, *savePath)
+		fmt.Fprintln(os.Stderr, "Waiting for input, saved to", *savePath)
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
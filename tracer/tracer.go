@@ -0,0 +1,102 @@
+package main
+
+import (
+	"advent-2019/intcode"
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func parseOpcodes(s string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(s, ",") {
+		opcode, ok := intcode.OpcodeForMnemonic(strings.TrimSpace(field))
+		if !ok {
+			return nil, fmt.Errorf("unknown opcode: %s", field)
+		}
+		values = append(values, opcode)
+	}
+	return values, nil
+}
+
+func main() {
+	jsonFormat := flag.Bool("json", false, "write the trace as JSON lines instead of text")
+	from := flag.Int("from", -1, "only trace instructions at or after this address")
+	to := flag.Int("to", -1, "only trace instructions before this address")
+	ops := flag.String("ops", "", "comma-separated mnemonics to trace (e.g. in,out,jz)")
+	input := flag.String("input", "", "comma-separated values to queue as input")
+	outputPath := flag.String("o", "", "file to write the trace to (defaults to stdout)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: tracer [flags] <tape file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := intcode.ReadTapeData(file)
+	file.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tape := intcode.CreateTapeCopy(data)
+	inputs, err := intcode.ParseValues(*input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, x := range inputs {
+		tape.Input(x)
+	}
+
+	output := os.Stdout
+	if *outputPath != "" {
+		if output, err = os.Create(*outputPath); err != nil {
+			log.Fatal(err)
+		}
+		defer output.Close()
+	}
+	writer := bufio.NewWriter(output)
+
+	format := intcode.TraceText
+	if *jsonFormat {
+		format = intcode.TraceJSON
+	}
+	tracer := intcode.NewTracer(writer, format)
+	if *from != -1 || *to != -1 {
+		end := *to
+		if end == -1 {
+			end = int(^uint(0) >> 1)
+		}
+		tracer.FilterAddresses(*from, end)
+	}
+	if *ops != "" {
+		opcodes, err := parseOpcodes(*ops)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tracer.FilterOpcodes(opcodes...)
+	}
+	tape.Attach(tracer)
+
+	runErr := tape.RunUntilHalt()
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := tracer.Err(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintln(os.Stderr, "output:", tape.PendingOutput())
+	if runErr != nil {
+		log.Fatal(runErr)
+	}
+}